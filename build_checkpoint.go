@@ -0,0 +1,125 @@
+package lifecycle
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+
+	"github.com/buildpacks/lifecycle/launch"
+	"github.com/buildpacks/lifecycle/layers"
+)
+
+// buildCheckpoint is the state of a build after its last successful buildpack.
+type buildCheckpoint struct {
+	Group      []GroupBuildpack `toml:"group"`
+	PlanDigest string           `toml:"plan-digest"`
+	// Completed holds a digest of the layers dir of each buildpack that finished, in group order.
+	Completed []completedBuildpack `toml:"completed"`
+	BOM       []BOMEntry           `toml:"bom"`
+	Labels    []Label              `toml:"labels"`
+	Processes []launch.Process     `toml:"processes"`
+	Slices    []layers.Slice       `toml:"slices"`
+	Stats     []BuildpackStats     `toml:"stats"`
+	Plan      BuildPlan            `toml:"plan"` // entries not yet met
+}
+
+type completedBuildpack struct {
+	GroupBuildpack
+	LayersDigest string `toml:"layers-digest"`
+}
+
+func (b *Builder) planDigest() (string, error) {
+	buf := &bytes.Buffer{}
+	if err := toml.NewEncoder(buf).Encode(b.Plan); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(buf.Bytes())), nil
+}
+
+// readCheckpoint returns the checkpoint at CheckpointPath if it was written for the same group and plan,
+// and the layers dirs of the buildpacks that finished are unchanged since.
+func (b *Builder) readCheckpoint(layersDir string) (*buildCheckpoint, error) {
+	var cp buildCheckpoint
+	if _, err := toml.DecodeFile(b.CheckpointPath, &cp); os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "reading build checkpoint")
+	}
+	planDigest, err := b.planDigest()
+	if err != nil {
+		return nil, err
+	}
+	if cp.PlanDigest != planDigest || len(cp.Group) != len(b.Group.Group) || len(cp.Completed) > len(cp.Group) {
+		return nil, nil
+	}
+	for i, bp := range b.Group.Group {
+		if cp.Group[i].String() != bp.String() {
+			return nil, nil
+		}
+	}
+	for _, bp := range cp.Completed {
+		digest, err := digestLayersDir(filepath.Join(layersDir, launch.EscapeID(bp.ID)))
+		if err != nil {
+			return nil, err
+		}
+		if digest != bp.LayersDigest {
+			return nil, nil
+		}
+	}
+	return &cp, nil
+}
+
+// writeCheckpoint replaces the checkpoint at CheckpointPath, so that an interrupted write leaves the previous checkpoint intact.
+func (b *Builder) writeCheckpoint(cp *buildCheckpoint, bp GroupBuildpack, layersDir string) error {
+	digest, err := digestLayersDir(filepath.Join(layersDir, launch.EscapeID(bp.ID)))
+	if err != nil {
+		return err
+	}
+	cp.Completed = append(cp.Completed, completedBuildpack{GroupBuildpack: bp.noOpt().noAPI().noHomepage(), LayersDigest: digest})
+	if err := os.MkdirAll(filepath.Dir(b.CheckpointPath), 0777); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(b.CheckpointPath), filepath.Base(b.CheckpointPath)+".tmp.")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := toml.NewEncoder(f).Encode(cp); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), b.CheckpointPath)
+}
+
+// digestLayersDir hashes the path, type, size and modification time of every file in dir.
+// File contents are not read, as layers may be large.
+func digestLayersDir(dir string) (string, error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return "", nil
+	}
+	h := sha256.New()
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s %s %d %d\n", filepath.ToSlash(rel), fi.Mode(), fi.Size(), fi.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
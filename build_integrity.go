@@ -0,0 +1,111 @@
+package lifecycle
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/buildpacks/lifecycle/launch"
+)
+
+// layersSnapshot maps each file a buildpack must not modify, relative to the layers dir, to a digest of its mode and contents.
+type layersSnapshot map[string]string
+
+// snapshotLayers records the files in the layers dir that belong to the lifecycle or to buildpacks other than bpID:
+// the top-level files and the config dir, and the layer metadata at the top of every other buildpack's layers dir.
+// The app and platform dirs are skipped if they are inside the layers dir.
+func snapshotLayers(config BuildConfig, bpID string) (layersSnapshot, error) {
+	snapshot := layersSnapshot{}
+	fis, err := ioutil.ReadDir(config.LayersDir)
+	if err != nil {
+		return nil, err
+	}
+	for _, fi := range fis {
+		path := filepath.Join(config.LayersDir, fi.Name())
+		switch {
+		case !fi.IsDir():
+			if err := snapshot.add(config.LayersDir, path, fi); err != nil {
+				return nil, err
+			}
+		case fi.Name() == launch.EscapeID(bpID), path == config.AppDir, path == config.PlatformDir:
+		case fi.Name() == "config":
+			if err := filepath.Walk(path, func(path string, fi os.FileInfo, err error) error {
+				if err != nil {
+					return err
+				}
+				return snapshot.add(config.LayersDir, path, fi)
+			}); err != nil {
+				return nil, err
+			}
+		default:
+			bpFIs, err := ioutil.ReadDir(path)
+			if err != nil {
+				return nil, err
+			}
+			for _, bpFI := range bpFIs {
+				if bpFI.IsDir() || filepath.Ext(bpFI.Name()) != ".toml" {
+					continue
+				}
+				if err := snapshot.add(config.LayersDir, filepath.Join(path, bpFI.Name()), bpFI); err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+	return snapshot, nil
+}
+
+func (s layersSnapshot) add(layersDir, path string, fi os.FileInfo) error {
+	rel, err := filepath.Rel(layersDir, path)
+	if err != nil {
+		return err
+	}
+	digest := fi.Mode().String()
+	if fi.Mode().IsRegular() {
+		contents, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		digest += fmt.Sprintf(" %x", sha256.Sum256(contents))
+	} else if fi.Mode()&os.ModeSymlink != 0 {
+		target, err := os.Readlink(path)
+		if err != nil {
+			return err
+		}
+		digest += " " + target
+	}
+	s[filepath.ToSlash(rel)] = digest
+	return nil
+}
+
+// changes lists the paths that were added, removed or modified in after, in sorted order.
+func (s layersSnapshot) changes(after layersSnapshot) []string {
+	var paths []string
+	for path := range s {
+		paths = append(paths, path)
+	}
+	for path := range after {
+		if _, ok := s[path]; !ok {
+			paths = append(paths, path)
+		}
+	}
+	sort.Strings(paths)
+
+	var out []string
+	for _, path := range paths {
+		before, inBefore := s[path]
+		afterDigest, inAfter := after[path]
+		switch {
+		case !inAfter:
+			out = append(out, fmt.Sprintf("removed '%s'", path))
+		case !inBefore:
+			out = append(out, fmt.Sprintf("added '%s'", path))
+		case before != afterDigest:
+			out = append(out, fmt.Sprintf("modified '%s'", path))
+		}
+	}
+	return out
+}
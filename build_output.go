@@ -0,0 +1,182 @@
+package lifecycle
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// OutputFormatRaw passes buildpack output through unchanged.
+	OutputFormatRaw = ""
+	// OutputFormatPrefixed prefixes each line of buildpack output with the buildpack and phase.
+	OutputFormatPrefixed = "prefixed"
+	// OutputFormatJSON writes each line of buildpack output to Out as an OutputRecord on a line of its own.
+	OutputFormatJSON = "json"
+)
+
+// outputDrainTimeout bounds how long the output of a buildpack process is read after it exits,
+// as children it left running in the background may hold its stdout or stderr open.
+const outputDrainTimeout = time.Second
+
+// OutputRecord is a line of buildpack output written in the json output format.
+type OutputRecord struct {
+	Time      time.Time `json:"ts"`
+	Buildpack string    `json:"buildpack"`
+	Phase     string    `json:"phase"`
+	Stream    string    `json:"stream"`
+	Line      string    `json:"line"`
+}
+
+// outputWriters returns the writers for the output of bp during phase, and a function that writes any
+// unterminated last line. Writes to the underlying writers are serialized by mu.
+// If masker is not nil, it is applied to each line.
+func outputWriters(format string, masker *strings.Replacer, out, errOut io.Writer, mu *sync.Mutex, bp GroupBuildpack, phase string) (io.Writer, io.Writer, func() error) {
+	if format == OutputFormatRaw && masker == nil {
+		return out, errOut, func() error { return nil }
+	}
+	newWriter := func(w io.Writer, stream string) *lineWriter {
+		lw := &lineWriter{mu: mu, raw: format == OutputFormatRaw}
+		switch format {
+		case OutputFormatJSON:
+			lw.writeLine = func(line string) error {
+				b, err := json.Marshal(OutputRecord{Time: time.Now().UTC(), Buildpack: bp.String(), Phase: phase, Stream: stream, Line: line})
+				if err != nil {
+					return err
+				}
+				_, err = out.Write(append(b, '\n'))
+				return err
+			}
+		case OutputFormatPrefixed:
+			lw.writeLine = func(line string) error {
+				_, err := fmt.Fprintf(w, "[%s:%s] %s\n", bp, phase, line)
+				return err
+			}
+		default:
+			lw.writeLine = func(line string) error {
+				_, err := io.WriteString(w, line)
+				return err
+			}
+		}
+		if masker != nil {
+			writeLine := lw.writeLine
+			lw.writeLine = func(line string) error {
+				return writeLine(masker.Replace(line))
+			}
+		}
+		return lw
+	}
+	stdout, stderr := newWriter(out, "stdout"), newWriter(errOut, "stderr")
+	return stdout, stderr, func() error {
+		if err := stdout.flush(); err != nil {
+			return err
+		}
+		return stderr.flush()
+	}
+}
+
+// lineWriter calls writeLine for each complete line written to it, without the line ending.
+// If raw is set, a carriage return also ends a line, so that progress bars are not held back,
+// and lines are passed with their line ending.
+type lineWriter struct {
+	mu        *sync.Mutex
+	buf       bytes.Buffer
+	raw       bool
+	writeLine func(line string) error
+}
+
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf.Write(p)
+	for {
+		if w.raw {
+			i := bytes.IndexAny(w.buf.Bytes(), "\r\n")
+			if i < 0 {
+				return len(p), nil
+			}
+			if err := w.emit(string(w.buf.Next(i + 1))); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		i := bytes.IndexByte(w.buf.Bytes(), '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		line := string(bytes.TrimSuffix(w.buf.Next(i + 1)[:i], []byte("\r")))
+		if err := w.emit(line); err != nil {
+			return 0, err
+		}
+	}
+}
+
+func (w *lineWriter) flush() error {
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	line := w.buf.String()
+	w.buf.Reset()
+	return w.emit(line)
+}
+
+func (w *lineWriter) emit(line string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.writeLine(line)
+}
+
+// outputPipe copies what a child process writes to the write end of a pipe into a writer.
+// exec.Cmd would copy from a writer that is not a file in a goroutine that Wait waits for,
+// which never returns while a background child holds the pipe open.
+// A file, such as the terminal with raw output, is given to the child process as is.
+type outputPipe struct {
+	r, w *os.File
+	done chan struct{}
+	err  error
+}
+
+func newOutputPipe(dst io.Writer) (*outputPipe, error) {
+	if f, ok := dst.(*os.File); ok {
+		done := make(chan struct{})
+		close(done)
+		return &outputPipe{w: f, done: done}, nil
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	p := &outputPipe{r: r, w: w, done: make(chan struct{})}
+	go func() {
+		defer close(p.done)
+		_, p.err = io.Copy(dst, r)
+	}()
+	return p, nil
+}
+
+// started closes the write end of the pipe once the child process holds its own copy.
+func (p *outputPipe) started() {
+	if p.r != nil {
+		p.w.Close()
+	}
+}
+
+// wait returns once the pipe is drained, or abandons it once outputDrainTimeout has passed.
+func (p *outputPipe) wait() error {
+	if p.r == nil {
+		return nil
+	}
+	p.w.Close()
+	select {
+	case <-p.done:
+	case <-time.After(outputDrainTimeout):
+		p.r.Close()
+		<-p.done
+		return nil
+	}
+	p.r.Close()
+	return p.err
+}
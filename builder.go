@@ -3,10 +3,16 @@ package lifecycle
 import (
 	"fmt"
 	"io"
+	"os"
 	"path/filepath"
 	"sort"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
 
 	"github.com/buildpacks/lifecycle/api"
+	"github.com/buildpacks/lifecycle/cmd"
 	"github.com/buildpacks/lifecycle/env"
 	"github.com/buildpacks/lifecycle/launch"
 	"github.com/buildpacks/lifecycle/layers"
@@ -25,6 +31,7 @@ type BuildpackStore interface {
 
 type Buildpack interface {
 	Build(bpPlan BuildpackPlan, config BuildConfig) (BuildResult, error)
+	ConfigFile() *BuildpackTOML
 }
 
 type BuildConfig struct {
@@ -34,6 +41,17 @@ type BuildConfig struct {
 	LayersDir   string
 	Out         io.Writer
 	Err         io.Writer
+	// EnvPolicy restricts the platform env vars provided to each buildpack; all are provided if it is nil.
+	EnvPolicy *EnvPolicy
+	// Logger receives warnings that don't fail the build; they are discarded if it is nil.
+	Logger Logger
+}
+
+func (c BuildConfig) logger() Logger {
+	if c.Logger == nil {
+		return discardLogger{}
+	}
+	return c.Logger
 }
 
 type BuildResult struct {
@@ -42,6 +60,16 @@ type BuildResult struct {
 	MetRequires []string
 	Processes   []launch.Process
 	Slices      []layers.Slice
+	Stats       BuildpackStats
+}
+
+// BuildpackStats measures a run of /bin/build.
+type BuildpackStats struct {
+	Buildpack   GroupBuildpack `toml:"buildpack"`
+	DurationMS  int64          `toml:"duration-ms"`
+	ExitCode    int            `toml:"exit-code"`
+	MaxRSS      int64          `toml:"max-rss"`      // peak resident set size in bytes, if known
+	LayersBytes int64          `toml:"layers-bytes"` // growth in size of the buildpack's layers dir
 }
 
 type BOMEntry struct {
@@ -68,30 +96,122 @@ type Builder struct {
 	Plan           BuildPlan
 	Out, Err       io.Writer
 	BuildpackStore BuildpackStore
+	// CheckpointPath records the progress of the build after each buildpack when Resume is set;
+	// nothing is recorded if it is empty. The checkpoint is removed once every buildpack has built.
+	CheckpointPath string
+	// Resume skips the buildpacks recorded in the checkpoint, provided that the group, plan
+	// and the layers dirs of those buildpacks are unchanged, and records a checkpoint for the next build.
+	Resume bool
+	// OutputFormat is one of OutputFormatRaw, OutputFormatPrefixed or OutputFormatJSON.
+	OutputFormat string
+	// StrictPlan fails the build if any build plan entry is not met by a buildpack in the group.
+	StrictPlan bool
+	// EnvPolicy restricts the platform env vars provided to each buildpack and masks secrets in buildpack output.
+	EnvPolicy *EnvPolicy
+	// Hooks are called around the build of each buildpack.
+	Hooks BuildHooks
+	// Logger defaults to discarding messages.
+	Logger Logger
+}
+
+// BuildHooks let platforms that embed the lifecycle observe, or stop, a build.
+// Hooks are not called for buildpacks skipped when resuming a build.
+type BuildHooks struct {
+	// BeforeBuildpack is called with the plan a buildpack is about to receive.
+	// If it returns an error, the build fails without running the buildpack.
+	BeforeBuildpack func(bp GroupBuildpack, plan BuildpackPlan) error
+	// AfterBuildpack is called once a buildpack has built, with its result and the error it failed with, if any.
+	// If it returns an error, the build fails.
+	AfterBuildpack func(bp GroupBuildpack, plan BuildpackPlan, result BuildResult, buildErr error) error
 }
 
+// Build runs each buildpack in the group. If a buildpack fails, the returned metadata holds the stats
+// of the buildpacks that ran, including the one that failed, alongside the error.
 func (b *Builder) Build() (*BuildMetadata, error) {
 	config, err := b.BuildConfig()
 	if err != nil {
 		return nil, err
 	}
 
+	planDigest, err := b.planDigest()
+	if err != nil {
+		return nil, err
+	}
+	cp := &buildCheckpoint{Group: b.Group.Group, PlanDigest: planDigest, Plan: b.Plan}
+	checkpointing := b.Resume && b.CheckpointPath != ""
+	if checkpointing {
+		prev, err := b.readCheckpoint(config.LayersDir)
+		if err != nil {
+			return nil, err
+		}
+		if prev != nil {
+			cp = prev
+		} else {
+			b.logger().Infof("No usable build checkpoint found, building all buildpacks")
+		}
+	}
+
+	var masker *strings.Replacer
+	if b.EnvPolicy != nil {
+		secrets, err := b.EnvPolicy.SecretValues(config.PlatformDir, config.Env.List())
+		if err != nil {
+			return nil, errors.Wrap(err, "reading secrets")
+		}
+		masker = NewSecretMasker(secrets)
+	}
+	outputMu := &sync.Mutex{}
 	procMap := processMap{}
-	plan := b.Plan
-	var bom []BOMEntry
-	var slices []layers.Slice
-	var labels []Label
+	procMap.add(cp.Processes)
+	plan := cp.Plan
+	bom := cp.BOM
+	slices := cp.Slices
+	labels := cp.Labels
+	stats := cp.Stats
 
-	for _, bp := range b.Group.Group {
+	for i, bp := range b.Group.Group {
 		bpTOML, err := b.BuildpackStore.Lookup(bp.ID, bp.Version)
 		if err != nil {
 			return nil, err
 		}
 
+		if i < len(cp.Completed) {
+			b.logger().Infof("Skipping buildpack '%s', which finished in a previous build", bp)
+			bpLayersDir := filepath.Join(config.LayersDir, launch.EscapeID(bp.ID))
+			if err := bpTOML.ConfigFile().setupEnv(config.Env, bpLayersDir); err != nil {
+				return nil, err
+			}
+			continue
+		}
+
 		bpPlan := plan.find(bp.ID)
-		br, err := bpTOML.Build(bpPlan, config)
+		if b.Hooks.BeforeBuildpack != nil {
+			if err := b.Hooks.BeforeBuildpack(bp, bpPlan); err != nil {
+				return nil, errors.Wrapf(err, "before buildpack '%s'", bp)
+			}
+		}
+		snapshot, err := snapshotLayers(config, bp.ID)
 		if err != nil {
-			return nil, err
+			return nil, errors.Wrap(err, "snapshotting layers dir")
+		}
+		bpConfig := config
+		var flushOutput func() error
+		bpConfig.Out, bpConfig.Err, flushOutput = outputWriters(b.OutputFormat, masker, config.Out, config.Err, outputMu, bp, "build")
+		br, err := bpTOML.Build(bpPlan, bpConfig)
+		if flushErr := flushOutput(); err == nil {
+			err = flushErr
+		}
+		if err == nil {
+			err = b.checkIntegrity(snapshot, bp, config)
+		}
+		br.Stats.Buildpack = bp.noOpt().noAPI().noHomepage()
+		if b.Hooks.AfterBuildpack != nil {
+			if hookErr := b.Hooks.AfterBuildpack(bp, bpPlan, br, err); hookErr != nil && err == nil {
+				err = errors.Wrapf(hookErr, "after buildpack '%s'", bp)
+			}
+		}
+		stats = append(stats, br.Stats)
+		if err != nil {
+			return &BuildMetadata{Buildpacks: b.Group.Group, Stats: stats}, err
 		}
 
 		bom = append(bom, br.BOM...)
@@ -99,6 +219,27 @@ func (b *Builder) Build() (*BuildMetadata, error) {
 		plan = plan.filter(br.MetRequires)
 		procMap.add(br.Processes)
 		slices = append(slices, br.Slices...)
+
+		if checkpointing {
+			cp.BOM, cp.Labels, cp.Processes, cp.Slices, cp.Stats, cp.Plan = bom, labels, procMap.list(), slices, stats, plan
+			if err := b.writeCheckpoint(cp, bp, config.LayersDir); err != nil {
+				return nil, errors.Wrap(err, "writing build checkpoint")
+			}
+		}
+	}
+
+	if b.CheckpointPath != "" {
+		if err := os.Remove(b.CheckpointPath); err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+	}
+
+	if b.StrictPlan && len(plan.Entries) > 0 {
+		var unmet []string
+		for _, entry := range plan.Entries {
+			unmet = append(unmet, entry.describe())
+		}
+		return nil, fmt.Errorf("build plan entries not met by any buildpack: %s", strings.Join(unmet, ", "))
 	}
 
 	if b.PlatformAPI.Compare(api.MustParse("0.4")) < 0 { // PlatformAPI <= 0.3
@@ -113,9 +254,177 @@ func (b *Builder) Build() (*BuildMetadata, error) {
 		Labels:     labels,
 		Processes:  procMap.list(),
 		Slices:     slices,
+		Stats:      stats,
+		Unmet:      plan.Entries,
 	}, nil
 }
 
+// BuildOptions configure Build.
+type BuildOptions struct {
+	AppDir      string
+	LayersDir   string
+	PlatformDir string
+	// PlatformAPI defaults to the latest supported platform API.
+	PlatformAPI *api.Version
+	// GroupPath and PlanPath default to the paths used by the builder command for PlatformAPI.
+	GroupPath string
+	PlanPath  string
+	// BuildpackStore resolves buildpacks; it defaults to a DirBuildpackStore for BuildpacksDir.
+	BuildpacksDir  string
+	BuildpackStore BuildpackStore
+	// Environ is the environment provided to buildpacks, before the platform env is applied.
+	// It defaults to the environment of the current process.
+	Environ []string
+	// Out and Err receive the output of buildpacks; they default to os.Stdout and os.Stderr.
+	Out, Err io.Writer
+	// Logger receives messages about the build; they are discarded if it is nil.
+	Logger Logger
+	Hooks  BuildHooks
+}
+
+// Build runs the build phase for platforms that embed the lifecycle: it reads the group and plan written by
+// the detector, builds each buildpack in the group and writes the build metadata for the exporter.
+// Unlike the builder command, it neither drops privileges nor exits on failure.
+// If a buildpack fails, the stats of the buildpacks that ran are returned with the error.
+func Build(opts BuildOptions) (*BuildMetadata, error) {
+	if opts.PlatformAPI == nil {
+		opts.PlatformAPI = api.Platform.Latest()
+	}
+	if opts.GroupPath == "" {
+		opts.GroupPath = cmd.DefaultGroupPath(opts.PlatformAPI.String(), opts.LayersDir)
+	}
+	if opts.PlanPath == "" {
+		opts.PlanPath = cmd.DefaultPlanPath(opts.PlatformAPI.String(), opts.LayersDir)
+	}
+	if opts.BuildpackStore == nil {
+		opts.BuildpackStore = &DirBuildpackStore{Dir: opts.BuildpacksDir}
+	}
+	if opts.Environ == nil {
+		opts.Environ = os.Environ()
+	}
+	if opts.Out == nil {
+		opts.Out = os.Stdout
+	}
+	if opts.Err == nil {
+		opts.Err = os.Stderr
+	}
+
+	group, err := ReadGroup(opts.GroupPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading buildpack group")
+	}
+	for _, bp := range group.Group {
+		if bp.API == "" {
+			// groups written by the detector set the API, but like the builder command default to 0.2
+			bp.API = "0.2"
+		}
+		bpAPI, err := api.NewVersion(bp.API)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parsing API of buildpack '%s'", bp)
+		}
+		if !api.Buildpack.IsSupported(bpAPI) {
+			return nil, fmt.Errorf("buildpack '%s' requests unsupported API '%s'", bp, bp.API)
+		}
+	}
+	plan, err := ReadPlan(opts.PlanPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "reading build plan")
+	}
+
+	builder := &Builder{
+		AppDir:         opts.AppDir,
+		LayersDir:      opts.LayersDir,
+		PlatformDir:    opts.PlatformDir,
+		PlatformAPI:    opts.PlatformAPI,
+		Env:            env.NewBuildEnv(opts.Environ),
+		Group:          group,
+		Plan:           plan,
+		Out:            opts.Out,
+		Err:            opts.Err,
+		BuildpackStore: opts.BuildpackStore,
+		Hooks:          opts.Hooks,
+		Logger:         opts.Logger,
+	}
+	md, err := builder.Build()
+	if err != nil {
+		return md, err
+	}
+	if err := WriteTOML(launch.GetMetadataFilePath(opts.LayersDir), md); err != nil {
+		return nil, errors.Wrap(err, "writing build metadata")
+	}
+	return md, nil
+}
+
+// DryRunReport is the plan that Build would hand to each buildpack in the group.
+type DryRunReport struct {
+	Buildpacks []DryRunBuildpack `toml:"buildpacks"`
+}
+
+type DryRunBuildpack struct {
+	Buildpack GroupBuildpack `toml:"buildpack"`
+	Plan      BuildpackPlan  `toml:"plan"`
+}
+
+// DryRun resolves every buildpack in the group and returns the plan each would receive, without running any.
+// As no buildpack runs, no entries are met, so each buildpack is shown every entry it provides.
+// It fails if a plan entry has no provider in the group.
+func (b *Builder) DryRun() (DryRunReport, error) {
+	var report DryRunReport
+	for _, bp := range b.Group.Group {
+		bpTOML, err := b.BuildpackStore.Lookup(bp.ID, bp.Version)
+		if err != nil {
+			return DryRunReport{}, err
+		}
+		resolved := bp.noOpt().noHomepage()
+		if bpAPI := bpTOML.ConfigFile().API; bpAPI != "" {
+			resolved.API = bpAPI
+		}
+		report.Buildpacks = append(report.Buildpacks, DryRunBuildpack{
+			Buildpack: resolved,
+			Plan:      b.Plan.find(bp.ID),
+		})
+	}
+	for _, entry := range b.Plan.Entries {
+		if !b.Group.hasProvider(entry) {
+			return DryRunReport{}, fmt.Errorf("build plan entry %s has no provider in the group", entry.describe())
+		}
+	}
+	return report, nil
+}
+
+func (bg BuildpackGroup) hasProvider(entry BuildPlanEntry) bool {
+	for _, provider := range entry.Providers {
+		for _, bp := range bg.Group {
+			if bp.ID == provider.ID {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// checkIntegrity fails if bp modified files in the layers dir that belong to the lifecycle or to other buildpacks.
+func (b *Builder) checkIntegrity(before layersSnapshot, bp GroupBuildpack, config BuildConfig) error {
+	after, err := snapshotLayers(config, bp.ID)
+	if err != nil {
+		return errors.Wrap(err, "snapshotting layers dir")
+	}
+	if changes := before.changes(after); len(changes) > 0 {
+		return NewLifecycleError(
+			fmt.Errorf("buildpack '%s' modified files outside of its layers directory: %s", bp, strings.Join(changes, ", ")),
+			ErrTypeBuildpack,
+		)
+	}
+	return nil
+}
+
+func (b *Builder) logger() Logger {
+	if b.Logger == nil {
+		return discardLogger{}
+	}
+	return b.Logger
+}
+
 func (b *Builder) BuildConfig() (BuildConfig, error) {
 	appDir, err := filepath.Abs(b.AppDir)
 	if err != nil {
@@ -137,6 +446,8 @@ func (b *Builder) BuildConfig() (BuildConfig, error) {
 		LayersDir:   layersDir,
 		Out:         b.Out,
 		Err:         b.Err,
+		EnvPolicy:   b.EnvPolicy,
+		Logger:      b.Logger,
 	}, nil
 }
 
@@ -164,6 +475,21 @@ func (p BuildPlan) filter(metRequires []string) BuildPlan {
 	return BuildPlan{Entries: out}
 }
 
+func (e BuildPlanEntry) describe() string {
+	var names, providers []string
+	seen := map[string]bool{}
+	for _, req := range e.Requires {
+		if !seen[req.Name] {
+			names = append(names, req.Name)
+			seen[req.Name] = true
+		}
+	}
+	for _, bp := range e.Providers {
+		providers = append(providers, bp.String())
+	}
+	return fmt.Sprintf("'%s' (provided by %s)", strings.Join(names, "', '"), strings.Join(providers, ", "))
+}
+
 func containsEntry(metRequires []string, entry BuildPlanEntry) bool {
 	for _, met := range metRequires {
 		for _, planReq := range entry.Requires {
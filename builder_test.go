@@ -2,14 +2,19 @@ package lifecycle_test
 
 import (
 	"bytes"
+	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
 	"github.com/golang/mock/gomock"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/pkg/errors"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
@@ -228,6 +233,75 @@ func testBuilder(t *testing.T, when spec.G, it spec.S) {
 					})
 				})
 
+				when("stats", func() {
+					it("should record the stats of each buildpack", func() {
+						bpA := testmock.NewMockBuildpack(mockCtrl)
+						buildpackStore.EXPECT().Lookup("A", "v1").Return(bpA, nil)
+						bpA.EXPECT().Build(gomock.Any(), config).Return(lifecycle.BuildResult{
+							Stats: lifecycle.BuildpackStats{DurationMS: 1500, MaxRSS: 1024, LayersBytes: 2048},
+						}, nil)
+						bpB := testmock.NewMockBuildpack(mockCtrl)
+						buildpackStore.EXPECT().Lookup("B", "v2").Return(bpB, nil)
+						bpB.EXPECT().Build(gomock.Any(), config).Return(lifecycle.BuildResult{
+							Stats: lifecycle.BuildpackStats{DurationMS: 10},
+						}, nil)
+
+						metadata, err := builder.Build()
+						if err != nil {
+							t.Fatalf("Unexpected error:\n%s\n", err)
+						}
+						if s := cmp.Diff(metadata.Stats, []lifecycle.BuildpackStats{
+							{Buildpack: lifecycle.GroupBuildpack{ID: "A", Version: "v1"}, DurationMS: 1500, MaxRSS: 1024, LayersBytes: 2048},
+							{Buildpack: lifecycle.GroupBuildpack{ID: "B", Version: "v2"}, DurationMS: 10},
+						}); s != "" {
+							t.Fatalf("Unexpected:\n%s\n", s)
+						}
+					})
+				})
+
+				when("unmet", func() {
+					var unmetEntry lifecycle.BuildPlanEntry
+
+					it.Before(func() {
+						unmetEntry = lifecycle.BuildPlanEntry{
+							Providers: []lifecycle.GroupBuildpack{{ID: "A", Version: "v1"}, {ID: "B", Version: "v2"}},
+							Requires:  []lifecycle.Require{{Name: "unmet-dep"}},
+						}
+						builder.Plan = lifecycle.BuildPlan{
+							Entries: []lifecycle.BuildPlanEntry{
+								{
+									Providers: []lifecycle.GroupBuildpack{{ID: "A", Version: "v1"}},
+									Requires:  []lifecycle.Require{{Name: "some-dep"}},
+								},
+								unmetEntry,
+							},
+						}
+						bpA := testmock.NewMockBuildpack(mockCtrl)
+						buildpackStore.EXPECT().Lookup("A", "v1").Return(bpA, nil)
+						bpA.EXPECT().Build(gomock.Any(), config).Return(lifecycle.BuildResult{MetRequires: []string{"some-dep"}}, nil)
+						bpB := testmock.NewMockBuildpack(mockCtrl)
+						buildpackStore.EXPECT().Lookup("B", "v2").Return(bpB, nil)
+						bpB.EXPECT().Build(gomock.Any(), config).Return(lifecycle.BuildResult{}, nil)
+					})
+
+					it("should list the entries no buildpack met", func() {
+						metadata, err := builder.Build()
+						if err != nil {
+							t.Fatalf("Unexpected error:\n%s\n", err)
+						}
+						if s := cmp.Diff(metadata.Unmet, []lifecycle.BuildPlanEntry{unmetEntry}); s != "" {
+							t.Fatalf("Unexpected:\n%s\n", s)
+						}
+					})
+
+					it("should fail in strict mode", func() {
+						builder.StrictPlan = true
+
+						_, err := builder.Build()
+						h.AssertError(t, err, "build plan entries not met by any buildpack: 'unmet-dep' (provided by A@v1, B@v2)")
+					})
+				})
+
 				when("labels", func() {
 					it("should aggregate labels from each buildpack", func() {
 						bpA := testmock.NewMockBuildpack(mockCtrl)
@@ -375,6 +449,176 @@ func testBuilder(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 
+		when("an output format is set", func() {
+			var writeOutput = func(_ lifecycle.BuildpackPlan, config lifecycle.BuildConfig) (lifecycle.BuildResult, error) {
+				fmt.Fprint(config.Out, "some output\nsome partial ")
+				fmt.Fprint(config.Err, "some error\r\n")
+				fmt.Fprint(config.Out, "line")
+				return lifecycle.BuildResult{}, nil
+			}
+
+			it.Before(func() {
+				bpA := testmock.NewMockBuildpack(mockCtrl)
+				buildpackStore.EXPECT().Lookup("A", "v1").Return(bpA, nil)
+				bpA.EXPECT().Build(gomock.Any(), gomock.Any()).DoAndReturn(writeOutput)
+				bpB := testmock.NewMockBuildpack(mockCtrl)
+				buildpackStore.EXPECT().Lookup("B", "v2").Return(bpB, nil)
+				bpB.EXPECT().Build(gomock.Any(), gomock.Any()).DoAndReturn(writeOutput)
+			})
+
+			it("should prefix each line with the buildpack and phase", func() {
+				builder.OutputFormat = lifecycle.OutputFormatPrefixed
+
+				_, err := builder.Build()
+				h.AssertNil(t, err)
+				h.AssertEq(t, stdout.String(), "[A@v1:build] some output\n[A@v1:build] some partial line\n"+
+					"[B@v2:build] some output\n[B@v2:build] some partial line\n")
+				h.AssertEq(t, stderr.String(), "[A@v1:build] some error\n[B@v2:build] some error\n")
+			})
+
+			it("should write each line to stdout as a json record", func() {
+				builder.OutputFormat = lifecycle.OutputFormatJSON
+
+				_, err := builder.Build()
+				h.AssertNil(t, err)
+				h.AssertEq(t, stderr.String(), "")
+
+				var records []lifecycle.OutputRecord
+				dec := json.NewDecoder(stdout)
+				for dec.More() {
+					var record lifecycle.OutputRecord
+					h.AssertNil(t, dec.Decode(&record))
+					if record.Time.IsZero() {
+						t.Fatalf("Missing timestamp: %+v", record)
+					}
+					records = append(records, record)
+				}
+				if s := cmp.Diff(records, []lifecycle.OutputRecord{
+					{Buildpack: "A@v1", Phase: "build", Stream: "stdout", Line: "some output"},
+					{Buildpack: "A@v1", Phase: "build", Stream: "stderr", Line: "some error"},
+					{Buildpack: "A@v1", Phase: "build", Stream: "stdout", Line: "some partial line"},
+					{Buildpack: "B@v2", Phase: "build", Stream: "stdout", Line: "some output"},
+					{Buildpack: "B@v2", Phase: "build", Stream: "stderr", Line: "some error"},
+					{Buildpack: "B@v2", Phase: "build", Stream: "stdout", Line: "some partial line"},
+				}, cmpopts.IgnoreFields(lifecycle.OutputRecord{}, "Time")); s != "" {
+					t.Fatalf("Unexpected records:\n%s\n", s)
+				}
+			})
+		})
+
+		when("an env policy names secrets", func() {
+			it.Before(func() {
+				builder.EnvPolicy = &lifecycle.EnvPolicy{Secrets: []string{"SOME_SECRET", "OTHER_SECRET"}}
+				h.Mkfile(t, "platform-secret\n", filepath.Join(platformDir, "env", "OTHER_SECRET"))
+				mockEnv.EXPECT().List().Return([]string{"SOME_SECRET=env-secret", "OTHER_VAR=not-secret"})
+			})
+
+			it("should mask their values in buildpack output", func() {
+				bpA := testmock.NewMockBuildpack(mockCtrl)
+				buildpackStore.EXPECT().Lookup("A", "v1").Return(bpA, nil)
+				bpA.EXPECT().Build(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ lifecycle.BuildpackPlan, config lifecycle.BuildConfig) (lifecycle.BuildResult, error) {
+						fmt.Fprint(config.Out, "using env-secret and not-secret\n")
+						fmt.Fprint(config.Err, "using platform-secret")
+						return lifecycle.BuildResult{}, nil
+					})
+				bpB := testmock.NewMockBuildpack(mockCtrl)
+				buildpackStore.EXPECT().Lookup("B", "v2").Return(bpB, nil)
+				bpB.EXPECT().Build(gomock.Any(), gomock.Any()).Return(lifecycle.BuildResult{}, nil)
+
+				_, err := builder.Build()
+				h.AssertNil(t, err)
+				h.AssertEq(t, stdout.String(), "using ******** and not-secret\n")
+				h.AssertEq(t, stderr.String(), "using ********")
+			})
+
+			it("should not hold back lines ended by a carriage return", func() {
+				bpA := testmock.NewMockBuildpack(mockCtrl)
+				buildpackStore.EXPECT().Lookup("A", "v1").Return(bpA, nil)
+				bpA.EXPECT().Build(gomock.Any(), gomock.Any()).DoAndReturn(
+					func(_ lifecycle.BuildpackPlan, config lifecycle.BuildConfig) (lifecycle.BuildResult, error) {
+						fmt.Fprint(config.Out, "fetching env-secret 10%\r")
+						h.AssertEq(t, stdout.String(), "fetching ******** 10%\r")
+						fmt.Fprint(config.Out, "fetching env-secret 100%\r\n")
+						return lifecycle.BuildResult{}, nil
+					})
+				bpB := testmock.NewMockBuildpack(mockCtrl)
+				buildpackStore.EXPECT().Lookup("B", "v2").Return(bpB, nil)
+				bpB.EXPECT().Build(gomock.Any(), gomock.Any()).Return(lifecycle.BuildResult{}, nil)
+
+				_, err := builder.Build()
+				h.AssertNil(t, err)
+				h.AssertEq(t, stdout.String(), "fetching ******** 10%\rfetching ******** 100%\r\n")
+			})
+		})
+
+		when("hooks are set", func() {
+			var calls []string
+
+			it.Before(func() {
+				calls = nil
+				builder.Plan = lifecycle.BuildPlan{
+					Entries: []lifecycle.BuildPlanEntry{
+						{
+							Providers: []lifecycle.GroupBuildpack{{ID: "A", Version: "v1"}},
+							Requires:  []lifecycle.Require{{Name: "some-dep"}},
+						},
+					},
+				}
+				builder.Hooks = lifecycle.BuildHooks{
+					BeforeBuildpack: func(bp lifecycle.GroupBuildpack, plan lifecycle.BuildpackPlan) error {
+						calls = append(calls, fmt.Sprintf("before %s %+v", bp, plan.Entries))
+						return nil
+					},
+					AfterBuildpack: func(bp lifecycle.GroupBuildpack, plan lifecycle.BuildpackPlan, result lifecycle.BuildResult, buildErr error) error {
+						calls = append(calls, fmt.Sprintf("after %s %+v %v %v %v", bp, plan.Entries, result.MetRequires, result.Stats.Buildpack, buildErr))
+						return nil
+					},
+				}
+			})
+
+			it("should call them around each buildpack", func() {
+				bpA := testmock.NewMockBuildpack(mockCtrl)
+				buildpackStore.EXPECT().Lookup("A", "v1").Return(bpA, nil)
+				bpA.EXPECT().Build(gomock.Any(), config).Return(lifecycle.BuildResult{MetRequires: []string{"some-dep"}}, nil)
+				bpB := testmock.NewMockBuildpack(mockCtrl)
+				buildpackStore.EXPECT().Lookup("B", "v2").Return(bpB, nil)
+				bpB.EXPECT().Build(gomock.Any(), config).Return(lifecycle.BuildResult{}, errors.New("some error"))
+
+				_, err := builder.Build()
+				h.AssertError(t, err, "some error")
+				h.AssertEq(t, calls, []string{
+					"before A@v1 [{Name:some-dep Version: Metadata:map[]}]",
+					"after A@v1 [{Name:some-dep Version: Metadata:map[]}] [some-dep] A@v1 <nil>",
+					"before B@v2 []",
+					"after B@v2 [] [] B@v2 some error",
+				})
+			})
+
+			it("should not build the buildpack if the before hook fails", func() {
+				builder.Hooks.BeforeBuildpack = func(bp lifecycle.GroupBuildpack, plan lifecycle.BuildpackPlan) error {
+					return errors.New("some hook error")
+				}
+				bpA := testmock.NewMockBuildpack(mockCtrl)
+				buildpackStore.EXPECT().Lookup("A", "v1").Return(bpA, nil)
+
+				_, err := builder.Build()
+				h.AssertError(t, err, "before buildpack 'A@v1': some hook error")
+			})
+
+			it("should fail the build if the after hook fails", func() {
+				builder.Hooks.AfterBuildpack = func(lifecycle.GroupBuildpack, lifecycle.BuildpackPlan, lifecycle.BuildResult, error) error {
+					return errors.New("some hook error")
+				}
+				bpA := testmock.NewMockBuildpack(mockCtrl)
+				buildpackStore.EXPECT().Lookup("A", "v1").Return(bpA, nil)
+				bpA.EXPECT().Build(gomock.Any(), config).Return(lifecycle.BuildResult{}, nil)
+
+				_, err := builder.Build()
+				h.AssertError(t, err, "after buildpack 'A@v1': some hook error")
+			})
+		})
+
 		when("building fails", func() {
 			when("first buildpack build fails", func() {
 				it("should error", func() {
@@ -405,6 +649,214 @@ func testBuilder(t *testing.T, when spec.G, it spec.S) {
 						t.Fatalf("Incorrect error: %s\n", err)
 					}
 				})
+
+				it("should return the stats of each buildpack that ran", func() {
+					bpA := testmock.NewMockBuildpack(mockCtrl)
+					buildpackStore.EXPECT().Lookup("A", "v1").Return(bpA, nil)
+					bpA.EXPECT().Build(gomock.Any(), config).Return(lifecycle.BuildResult{
+						Stats: lifecycle.BuildpackStats{DurationMS: 1500},
+					}, nil)
+					bpB := testmock.NewMockBuildpack(mockCtrl)
+					buildpackStore.EXPECT().Lookup("B", "v2").Return(bpB, nil)
+					bpB.EXPECT().Build(gomock.Any(), config).Return(lifecycle.BuildResult{
+						Stats: lifecycle.BuildpackStats{DurationMS: 10, ExitCode: 42},
+					}, errors.New("some error"))
+
+					metadata, err := builder.Build()
+					h.AssertError(t, err, "some error")
+					if s := cmp.Diff(metadata.Stats, []lifecycle.BuildpackStats{
+						{Buildpack: lifecycle.GroupBuildpack{ID: "A", Version: "v1"}, DurationMS: 1500},
+						{Buildpack: lifecycle.GroupBuildpack{ID: "B", Version: "v2"}, DurationMS: 10, ExitCode: 42},
+					}); s != "" {
+						t.Fatalf("Unexpected:\n%s\n", s)
+					}
+				})
+			})
+
+			when("a buildpack modifies files outside of its layers directory", func() {
+				it.Before(func() {
+					h.Mkdir(t, filepath.Join(layersDir, "A", "some-layer"), filepath.Join(layersDir, "config"))
+					h.Mkfile(t, "[types]\nbuild = true\n", filepath.Join(layersDir, "A", "some-layer.toml"))
+					h.Mkfile(t, "[[group]]\n", filepath.Join(layersDir, "group.toml"))
+				})
+
+				it("should fail with the buildpack named if it modifies another buildpack's layer metadata", func() {
+					bpA := testmock.NewMockBuildpack(mockCtrl)
+					buildpackStore.EXPECT().Lookup("A", "v1").Return(bpA, nil)
+					bpA.EXPECT().Build(gomock.Any(), config).Return(lifecycle.BuildResult{}, nil)
+					bpB := testmock.NewMockBuildpack(mockCtrl)
+					buildpackStore.EXPECT().Lookup("B", "v2").Return(bpB, nil)
+					bpB.EXPECT().Build(gomock.Any(), config).DoAndReturn(
+						func(_ lifecycle.BuildpackPlan, config lifecycle.BuildConfig) (lifecycle.BuildResult, error) {
+							h.Mkfile(t, "[types]\nlaunch = true\n", filepath.Join(config.LayersDir, "A", "some-layer.toml"))
+							h.Mkfile(t, "", filepath.Join(config.LayersDir, "A", "other-layer.toml"))
+							return lifecycle.BuildResult{}, nil
+						})
+
+					_, err := builder.Build()
+					if err, ok := err.(*lifecycle.Error); !ok || err.Type != lifecycle.ErrTypeBuildpack {
+						t.Fatalf("Unexpected error:\n%s\n", err)
+					}
+					h.AssertError(t, err, "buildpack 'B@v2' modified files outside of its layers directory: added 'A/other-layer.toml', modified 'A/some-layer.toml'")
+				})
+
+				it("should fail if it modifies the lifecycle's files", func() {
+					bpA := testmock.NewMockBuildpack(mockCtrl)
+					buildpackStore.EXPECT().Lookup("A", "v1").Return(bpA, nil)
+					bpA.EXPECT().Build(gomock.Any(), config).DoAndReturn(
+						func(_ lifecycle.BuildpackPlan, config lifecycle.BuildConfig) (lifecycle.BuildResult, error) {
+							h.Mkfile(t, "", filepath.Join(config.LayersDir, "config", "metadata.toml"))
+							if err := os.Remove(filepath.Join(config.LayersDir, "group.toml")); err != nil {
+								t.Fatal(err)
+							}
+							return lifecycle.BuildResult{}, nil
+						})
+
+					_, err := builder.Build()
+					h.AssertError(t, err, "buildpack 'A@v1' modified files outside of its layers directory: added 'config/metadata.toml', removed 'group.toml'")
+				})
+
+				it("should allow changes to its own layers directory and the app dir", func() {
+					bpA := testmock.NewMockBuildpack(mockCtrl)
+					buildpackStore.EXPECT().Lookup("A", "v1").Return(bpA, nil)
+					bpA.EXPECT().Build(gomock.Any(), config).DoAndReturn(
+						func(_ lifecycle.BuildpackPlan, config lifecycle.BuildConfig) (lifecycle.BuildResult, error) {
+							h.Mkfile(t, "", filepath.Join(config.LayersDir, "A", "some-layer.toml"))
+							h.Mkfile(t, "", filepath.Join(config.AppDir, "some-file.toml"))
+							return lifecycle.BuildResult{}, nil
+						})
+					bpB := testmock.NewMockBuildpack(mockCtrl)
+					buildpackStore.EXPECT().Lookup("B", "v2").Return(bpB, nil)
+					bpB.EXPECT().Build(gomock.Any(), config).Return(lifecycle.BuildResult{}, nil)
+
+					_, err := builder.Build()
+					h.AssertNil(t, err)
+				})
+			})
+		})
+
+		when("a checkpoint path is set without resuming", func() {
+			it("should not write a checkpoint", func() {
+				checkpointPath := filepath.Join(layersDir, "build-checkpoint.toml")
+				builder.CheckpointPath = checkpointPath
+
+				bpA := testmock.NewMockBuildpack(mockCtrl)
+				buildpackStore.EXPECT().Lookup("A", "v1").Return(bpA, nil)
+				bpA.EXPECT().Build(gomock.Any(), config).Return(lifecycle.BuildResult{}, nil)
+				bpB := testmock.NewMockBuildpack(mockCtrl)
+				buildpackStore.EXPECT().Lookup("B", "v2").Return(bpB, nil)
+				bpB.EXPECT().Build(gomock.Any(), config).Return(lifecycle.BuildResult{}, errors.New("some error"))
+
+				_, err := builder.Build()
+				h.AssertError(t, err, "some error")
+				h.AssertPathDoesNotExist(t, checkpointPath)
+			})
+		})
+
+		when("a checkpoint path is set", func() {
+			var checkpointPath string
+
+			it.Before(func() {
+				checkpointPath = filepath.Join(layersDir, "build-checkpoint.toml")
+				builder.CheckpointPath = checkpointPath
+				builder.Resume = true // without a Logger, as library callers may leave it unset
+				builder.Plan = lifecycle.BuildPlan{
+					Entries: []lifecycle.BuildPlanEntry{
+						{
+							Providers: []lifecycle.GroupBuildpack{{ID: "A", Version: "v1"}, {ID: "B", Version: "v2"}},
+							Requires:  []lifecycle.Require{{Name: "some-dep"}},
+						},
+					},
+				}
+
+				h.Mkdir(t, filepath.Join(layersDir, "A", "some-layer"))
+				bpA := testmock.NewMockBuildpack(mockCtrl)
+				buildpackStore.EXPECT().Lookup("A", "v1").Return(bpA, nil)
+				bpA.EXPECT().Build(gomock.Any(), config).Return(lifecycle.BuildResult{
+					BOM:         []lifecycle.BOMEntry{{Require: lifecycle.Require{Name: "some-dep"}, Buildpack: lifecycle.GroupBuildpack{ID: "A", Version: "v1"}}},
+					MetRequires: []string{"some-dep"},
+					Processes:   []launch.Process{{Type: "web", Command: "some-command", BuildpackID: "A"}},
+				}, nil)
+				bpB := testmock.NewMockBuildpack(mockCtrl)
+				buildpackStore.EXPECT().Lookup("B", "v2").Return(bpB, nil)
+				bpB.EXPECT().Build(gomock.Any(), config).Return(lifecycle.BuildResult{}, errors.New("some error"))
+
+				_, err := builder.Build()
+				h.AssertError(t, err, "some error")
+				h.AssertPathExists(t, checkpointPath)
+			})
+
+			when("resuming", func() {
+				it("should skip buildpacks that finished in the previous build", func() {
+					bpA := testmock.NewMockBuildpack(mockCtrl)
+					buildpackStore.EXPECT().Lookup("A", "v1").Return(bpA, nil)
+					bpA.EXPECT().ConfigFile().Return(&lifecycle.BuildpackTOML{API: "0.5"})
+					bpB := testmock.NewMockBuildpack(mockCtrl)
+					buildpackStore.EXPECT().Lookup("B", "v2").Return(bpB, nil)
+					bpB.EXPECT().Build(lifecycle.BuildpackPlan{}, config).Return(lifecycle.BuildResult{
+						Processes: []launch.Process{{Type: "worker", Command: "other-command", BuildpackID: "B"}},
+					}, nil)
+
+					metadata, err := builder.Build()
+					h.AssertNil(t, err)
+					if s := cmp.Diff(metadata.BOM, []lifecycle.BOMEntry{
+						{Require: lifecycle.Require{Name: "some-dep"}, Buildpack: lifecycle.GroupBuildpack{ID: "A", Version: "v1"}},
+					}); s != "" {
+						t.Fatalf("Unexpected BOM:\n%s\n", s)
+					}
+					if s := cmp.Diff(metadata.Processes, []launch.Process{
+						{Type: "web", Command: "some-command", BuildpackID: "A"},
+						{Type: "worker", Command: "other-command", BuildpackID: "B"},
+					}); s != "" {
+						t.Fatalf("Unexpected processes:\n%s\n", s)
+					}
+					h.AssertPathDoesNotExist(t, checkpointPath)
+				})
+
+				it("should log skipped buildpacks", func() {
+					logHandler := memory.New()
+					builder.Logger = &log.Logger{Handler: logHandler}
+
+					bpA := testmock.NewMockBuildpack(mockCtrl)
+					buildpackStore.EXPECT().Lookup("A", "v1").Return(bpA, nil)
+					bpA.EXPECT().ConfigFile().Return(&lifecycle.BuildpackTOML{API: "0.5"})
+					bpB := testmock.NewMockBuildpack(mockCtrl)
+					buildpackStore.EXPECT().Lookup("B", "v2").Return(bpB, nil)
+					bpB.EXPECT().Build(gomock.Any(), gomock.Any()).Return(lifecycle.BuildResult{}, nil)
+
+					_, err := builder.Build()
+					h.AssertNil(t, err)
+					h.AssertEq(t, len(logHandler.Entries), 1)
+					h.AssertEq(t, logHandler.Entries[0].Message, "Skipping buildpack 'A@v1', which finished in a previous build")
+				})
+
+				it("should rebuild every buildpack if the layers of a finished buildpack changed", func() {
+					h.Mkdir(t, filepath.Join(layersDir, "A", "other-layer"))
+
+					bpA := testmock.NewMockBuildpack(mockCtrl)
+					buildpackStore.EXPECT().Lookup("A", "v1").Return(bpA, nil)
+					bpA.EXPECT().Build(gomock.Any(), config).Return(lifecycle.BuildResult{}, nil)
+					bpB := testmock.NewMockBuildpack(mockCtrl)
+					buildpackStore.EXPECT().Lookup("B", "v2").Return(bpB, nil)
+					bpB.EXPECT().Build(gomock.Any(), config).Return(lifecycle.BuildResult{}, nil)
+
+					_, err := builder.Build()
+					h.AssertNil(t, err)
+				})
+
+				it("should rebuild every buildpack if the plan changed", func() {
+					builder.Plan.Entries[0].Requires[0].Name = "other-dep"
+
+					bpA := testmock.NewMockBuildpack(mockCtrl)
+					buildpackStore.EXPECT().Lookup("A", "v1").Return(bpA, nil)
+					bpA.EXPECT().Build(gomock.Any(), config).Return(lifecycle.BuildResult{}, nil)
+					bpB := testmock.NewMockBuildpack(mockCtrl)
+					buildpackStore.EXPECT().Lookup("B", "v2").Return(bpB, nil)
+					bpB.EXPECT().Build(gomock.Any(), config).Return(lifecycle.BuildResult{}, nil)
+
+					_, err := builder.Build()
+					h.AssertNil(t, err)
+				})
 			})
 		})
 
@@ -455,4 +907,116 @@ func testBuilder(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 	})
+
+	when("#DryRun", func() {
+		it.Before(func() {
+			builder.Plan = lifecycle.BuildPlan{
+				Entries: []lifecycle.BuildPlanEntry{
+					{
+						Providers: []lifecycle.GroupBuildpack{{ID: "A", Version: "v1"}, {ID: "B", Version: "v2"}},
+						Requires:  []lifecycle.Require{{Name: "some-dep"}},
+					},
+					{
+						Providers: []lifecycle.GroupBuildpack{{ID: "B", Version: "v2"}},
+						Requires:  []lifecycle.Require{{Name: "other-dep", Version: "v4"}},
+					},
+				},
+			}
+		})
+
+		it("should return the plan for each buildpack without building", func() {
+			bpA := testmock.NewMockBuildpack(mockCtrl)
+			buildpackStore.EXPECT().Lookup("A", "v1").Return(bpA, nil)
+			bpA.EXPECT().ConfigFile().Return(&lifecycle.BuildpackTOML{API: "0.5"})
+			bpB := testmock.NewMockBuildpack(mockCtrl)
+			buildpackStore.EXPECT().Lookup("B", "v2").Return(bpB, nil)
+			bpB.EXPECT().ConfigFile().Return(&lifecycle.BuildpackTOML{API: "0.4"})
+
+			report, err := builder.DryRun()
+			h.AssertNil(t, err)
+			if s := cmp.Diff(report, lifecycle.DryRunReport{
+				Buildpacks: []lifecycle.DryRunBuildpack{
+					{
+						Buildpack: lifecycle.GroupBuildpack{ID: "A", Version: "v1", API: "0.5"},
+						Plan:      lifecycle.BuildpackPlan{Entries: []lifecycle.Require{{Name: "some-dep"}}},
+					},
+					{
+						Buildpack: lifecycle.GroupBuildpack{ID: "B", Version: "v2", API: "0.4"},
+						Plan:      lifecycle.BuildpackPlan{Entries: []lifecycle.Require{{Name: "some-dep"}, {Name: "other-dep", Version: "v4"}}},
+					},
+				},
+			}); s != "" {
+				t.Fatalf("Unexpected report:\n%s\n", s)
+			}
+		})
+
+		it("should fail if a buildpack cannot be found", func() {
+			buildpackStore.EXPECT().Lookup("A", "v1").Return(nil, errors.New("some error"))
+
+			_, err := builder.DryRun()
+			h.AssertError(t, err, "some error")
+		})
+
+		it("should fail if a plan entry has no provider in the group", func() {
+			builder.Plan.Entries[1].Providers = []lifecycle.GroupBuildpack{{ID: "C", Version: "v3"}}
+			for _, id := range []string{"A", "B"} {
+				bp := testmock.NewMockBuildpack(mockCtrl)
+				buildpackStore.EXPECT().Lookup(id, gomock.Any()).Return(bp, nil)
+				bp.EXPECT().ConfigFile().Return(&lifecycle.BuildpackTOML{API: "0.5"})
+			}
+
+			_, err := builder.DryRun()
+			h.AssertError(t, err, "build plan entry 'other-dep' (provided by C@v3) has no provider in the group")
+		})
+	})
+
+	when("Build", func() {
+		it("should build the group written by the detector and write the build metadata", func() {
+			h.Mkfile(t, "[[group]]\nid = \"A\"\nversion = \"v1\"\napi = \"0.3\"\n", filepath.Join(layersDir, "group.toml"))
+			h.Mkfile(t, "", filepath.Join(layersDir, "plan.toml"))
+			h.AssertNil(t, os.MkdirAll(filepath.Join(platformDir, "env"), 0777))
+			h.Mkfile(t, "Av1", filepath.Join(platformDir, "env", "TEST_ENV"))
+			buildpacksDir, err := filepath.Abs(filepath.Join("testdata", "by-id"))
+			h.AssertNil(t, err)
+
+			var built []string
+			md, err := lifecycle.Build(lifecycle.BuildOptions{
+				AppDir:        appDir,
+				LayersDir:     layersDir,
+				PlatformDir:   platformDir,
+				BuildpacksDir: buildpacksDir,
+				Out:           stdout,
+				Err:           stderr,
+				Hooks: lifecycle.BuildHooks{
+					AfterBuildpack: func(bp lifecycle.GroupBuildpack, _ lifecycle.BuildpackPlan, _ lifecycle.BuildResult, buildErr error) error {
+						h.AssertNil(t, buildErr)
+						built = append(built, bp.String())
+						return nil
+					},
+				},
+			})
+			h.AssertNil(t, err)
+			h.AssertEq(t, built, []string{"A@v1"})
+			h.AssertEq(t, md.Buildpacks, []lifecycle.GroupBuildpack{{ID: "A", Version: "v1", API: "0.3"}})
+			h.AssertPathExists(t, launch.GetMetadataFilePath(layersDir))
+			h.AssertStringContains(t, stdout.String(), "build out: A@v1")
+			h.AssertEq(t, h.Rdfile(t, filepath.Join(appDir, "build-info-A-v1")), "TEST_ENV: Av1\n")
+		})
+
+		it("should fail if the group requests an unsupported buildpack API", func() {
+			h.Mkfile(t, "[[group]]\nid = \"A\"\nversion = \"v1\"\napi = \"0.1\"\n", filepath.Join(layersDir, "group.toml"))
+			h.Mkfile(t, "", filepath.Join(layersDir, "plan.toml"))
+
+			_, err := lifecycle.Build(lifecycle.BuildOptions{AppDir: appDir, LayersDir: layersDir, PlatformDir: platformDir})
+			h.AssertError(t, err, "buildpack 'A@v1' requests unsupported API '0.1'")
+		})
+
+		it("should fail if the group requests a malformed buildpack API", func() {
+			h.Mkfile(t, "[[group]]\nid = \"A\"\nversion = \"v1\"\napi = \"latest\"\n", filepath.Join(layersDir, "group.toml"))
+			h.Mkfile(t, "", filepath.Join(layersDir, "plan.toml"))
+
+			_, err := lifecycle.Build(lifecycle.BuildOptions{AppDir: appDir, LayersDir: layersDir, PlatformDir: platformDir})
+			h.AssertError(t, err, "parsing API of buildpack 'A@v1'")
+		})
+	})
 }
@@ -1,13 +1,5 @@
 package lifecycle
 
-import (
-	"path/filepath"
-
-	"github.com/BurntSushi/toml"
-
-	"github.com/buildpacks/lifecycle/launch"
-)
-
 type GroupBuildpack struct {
 	ID       string `toml:"id" json:"id"`
 	Version  string `toml:"version" json:"version"`
@@ -36,17 +28,15 @@ func (bp GroupBuildpack) noHomepage() GroupBuildpack {
 }
 
 func (bp GroupBuildpack) Lookup(buildpacksDir string) (*BuildpackTOML, error) {
-	bpTOML := BuildpackTOML{}
-	bpPath, err := filepath.Abs(filepath.Join(buildpacksDir, launch.EscapeID(bp.ID), bp.Version))
+	return bp.LookupIn(&DirBuildpackStore{Dir: buildpacksDir})
+}
+
+func (bp GroupBuildpack) LookupIn(store BuildpackStore) (*BuildpackTOML, error) {
+	buildpack, err := store.Lookup(bp.ID, bp.Version)
 	if err != nil {
 		return nil, err
 	}
-	tomlPath := filepath.Join(bpPath, "buildpack.toml")
-	if _, err := toml.DecodeFile(tomlPath, &bpTOML); err != nil {
-		return nil, err
-	}
-	bpTOML.Dir = bpPath
-	return &bpTOML, nil
+	return buildpack.ConfigFile(), nil
 }
 
 type BuildpackInfo struct {
@@ -0,0 +1,244 @@
+package lifecycle
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/pkg/errors"
+
+	"github.com/buildpacks/lifecycle/archive"
+	"github.com/buildpacks/lifecycle/launch"
+	"github.com/buildpacks/lifecycle/layers"
+)
+
+const BuildpackLayersLabel = "io.buildpacks.buildpack.layers"
+
+// TarballBuildpackStore resolves buildpacks from gzipped buildpack tarballs (*.tgz) in Dir.
+// Each tarball contains a buildpack.toml at its root and is extracted to ScratchDir the first time it is looked up.
+type TarballBuildpackStore struct {
+	Dir        string
+	ScratchDir string
+
+	mu    sync.Mutex
+	index map[string]string // buildpack ID@version to tarball path
+}
+
+func (s *TarballBuildpackStore) Lookup(bpID, bpVersion string) (Buildpack, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	bpDir := filepath.Join(s.ScratchDir, launch.EscapeID(bpID), bpVersion)
+	if _, err := os.Stat(bpDir); os.IsNotExist(err) {
+		if s.index == nil {
+			if err := s.readIndex(); err != nil {
+				return nil, err
+			}
+		}
+		path, ok := s.index[bpID+"@"+bpVersion]
+		if !ok {
+			return nil, errors.Errorf("buildpack '%s@%s' not found in '%s'", bpID, bpVersion, s.Dir)
+		}
+		if err := extractTarball(path, bpDir); err != nil {
+			return nil, errors.Wrapf(err, "extracting buildpack '%s@%s'", bpID, bpVersion)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return (&DirBuildpackStore{Dir: s.ScratchDir}).Lookup(bpID, bpVersion)
+}
+
+func (s *TarballBuildpackStore) readIndex() error {
+	paths, err := filepath.Glob(filepath.Join(s.Dir, "*.tgz"))
+	if err != nil {
+		return err
+	}
+	index := map[string]string{}
+	for _, path := range paths {
+		bpTOML, err := readTarballBuildpackTOML(path)
+		if err != nil {
+			return errors.Wrapf(err, "reading buildpack.toml from '%s'", path)
+		}
+		index[bpTOML.Buildpack.ID+"@"+bpTOML.Buildpack.Version] = path
+	}
+	s.index = index
+	return nil
+}
+
+func openTarball(path string) (*tar.Reader, io.Closer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	gzr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return tar.NewReader(gzr), f, nil
+}
+
+func readTarballBuildpackTOML(path string) (BuildpackTOML, error) {
+	tr, closer, err := openTarball(path)
+	if err != nil {
+		return BuildpackTOML{}, err
+	}
+	defer closer.Close()
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return BuildpackTOML{}, errors.New("missing buildpack.toml")
+		}
+		if err != nil {
+			return BuildpackTOML{}, err
+		}
+		if strings.TrimPrefix(hdr.Name, "./") != "buildpack.toml" {
+			continue
+		}
+		var bpTOML BuildpackTOML
+		if _, err := toml.DecodeReader(tr, &bpTOML); err != nil {
+			return BuildpackTOML{}, err
+		}
+		return bpTOML, nil
+	}
+}
+
+func extractTarball(path, dest string) error {
+	tr, closer, err := openTarball(path)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+	return extractToDir(dest, ".", func(dir string) error {
+		ntr := archive.NewNormalizingTarReader(tr)
+		ntr.PrependDir(dir)
+		return archive.Extract(ntr)
+	})
+}
+
+// extractToDir calls extract with a temporary sibling of dest and renames root within it into place,
+// so that an interrupted extraction is never mistaken for a complete buildpack.
+func extractToDir(dest, root string, extract func(dir string) error) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0777); err != nil {
+		return err
+	}
+	tmpDir, err := ioutil.TempDir(filepath.Dir(dest), filepath.Base(dest)+".")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	if err := extract(tmpDir); err != nil {
+		return err
+	}
+	return os.Rename(filepath.Join(tmpDir, root), dest)
+}
+
+// OCILayoutBuildpackStore resolves buildpacks from buildpackage images in the OCI image layout at Dir.
+// Buildpack layers are located using the io.buildpacks.buildpack.layers label and are extracted
+// to ScratchDir the first time they are looked up.
+type OCILayoutBuildpackStore struct {
+	Dir        string
+	ScratchDir string
+
+	mu    sync.Mutex
+	index map[string]v1.Layer // buildpack ID@version to layer
+}
+
+type buildpackLayerInfo struct {
+	LayerDiffID string `json:"layerDiffID"`
+}
+
+func (s *OCILayoutBuildpackStore) Lookup(bpID, bpVersion string) (Buildpack, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	buildpacksDir := filepath.Join(s.ScratchDir, "cnb", "buildpacks")
+	bpDir := filepath.Join(buildpacksDir, launch.EscapeID(bpID), bpVersion)
+	if _, err := os.Stat(bpDir); os.IsNotExist(err) {
+		if s.index == nil {
+			if err := s.readIndex(); err != nil {
+				return nil, errors.Wrapf(err, "reading OCI layout '%s'", s.Dir)
+			}
+		}
+		layer, ok := s.index[bpID+"@"+bpVersion]
+		if !ok {
+			return nil, errors.Errorf("buildpack '%s@%s' not found in '%s'", bpID, bpVersion, s.Dir)
+		}
+		if err := extractLayer(layer, s.ScratchDir, bpDir); err != nil {
+			return nil, errors.Wrapf(err, "extracting buildpack '%s@%s'", bpID, bpVersion)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return (&DirBuildpackStore{Dir: buildpacksDir}).Lookup(bpID, bpVersion)
+}
+
+func (s *OCILayoutBuildpackStore) readIndex() error {
+	idx, err := layout.ImageIndexFromPath(s.Dir)
+	if err != nil {
+		return err
+	}
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return err
+	}
+	index := map[string]v1.Layer{}
+	for _, desc := range manifest.Manifests {
+		img, err := idx.Image(desc.Digest)
+		if err != nil {
+			return err
+		}
+		config, err := img.ConfigFile()
+		if err != nil {
+			return err
+		}
+		label, ok := config.Config.Labels[BuildpackLayersLabel]
+		if !ok {
+			continue
+		}
+		var bpLayers map[string]map[string]buildpackLayerInfo
+		if err := json.Unmarshal([]byte(label), &bpLayers); err != nil {
+			return errors.Wrapf(err, "parsing label '%s'", BuildpackLayersLabel)
+		}
+		for id, versions := range bpLayers {
+			for version, info := range versions {
+				diffID, err := v1.NewHash(info.LayerDiffID)
+				if err != nil {
+					return err
+				}
+				layer, err := img.LayerByDiffID(diffID)
+				if err != nil {
+					return err
+				}
+				index[id+"@"+version] = layer
+			}
+		}
+	}
+	s.index = index
+	return nil
+}
+
+// extractLayer extracts the buildpack at bpDir from a layer holding paths relative to scratchDir.
+func extractLayer(layer v1.Layer, scratchDir, bpDir string) error {
+	rc, err := layer.Uncompressed()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	root, err := filepath.Rel(scratchDir, bpDir)
+	if err != nil {
+		return err
+	}
+	return extractToDir(bpDir, root, func(dir string) error {
+		return layers.Extract(rc, dir)
+	})
+}
@@ -0,0 +1,168 @@
+package lifecycle_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestBuildpackStore(t *testing.T) {
+	spec.Run(t, "BuildpackStore", testBuildpackStore, spec.Report(report.Terminal{}))
+}
+
+func testBuildpackStore(t *testing.T, when spec.G, it spec.S) {
+	var tmpDir, scratchDir string
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "lifecycle.buildpack-store")
+		h.AssertNil(t, err)
+		scratchDir = filepath.Join(tmpDir, "scratch")
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	bpTOML := func(id, version string) string {
+		return fmt.Sprintf("api = \"0.4\"\n\n[buildpack]\nid = \"%s\"\nname = \"Buildpack %s\"\nversion = \"%s\"\n", id, id, version)
+	}
+
+	when("TarballBuildpackStore", func() {
+		var store *lifecycle.TarballBuildpackStore
+
+		it.Before(func() {
+			tarballsDir := filepath.Join(tmpDir, "tarballs")
+			h.Mkdir(t, tarballsDir)
+			writeTarball(t, filepath.Join(tarballsDir, "some-buildpack.tgz"), true, map[string]string{
+				"./buildpack.toml": bpTOML("some/buildpack", "1.2.3"),
+				"./bin/detect":     "#!/usr/bin/env bash\nexit 0\n",
+			})
+			writeTarball(t, filepath.Join(tarballsDir, "other-buildpack.tgz"), true, map[string]string{
+				"buildpack.toml": bpTOML("other/buildpack", "4.5.6"),
+			})
+			store = &lifecycle.TarballBuildpackStore{Dir: tarballsDir, ScratchDir: scratchDir}
+		})
+
+		it("should extract the matching tarball and return its buildpack", func() {
+			bp, err := store.Lookup("some/buildpack", "1.2.3")
+			h.AssertNil(t, err)
+
+			bpDir := filepath.Join(scratchDir, "some_buildpack", "1.2.3")
+			h.AssertEq(t, bp.ConfigFile().Buildpack.ID, "some/buildpack")
+			h.AssertEq(t, bp.ConfigFile().API, "0.4")
+			h.AssertEq(t, bp.ConfigFile().Dir, bpDir)
+			h.AssertPathExists(t, filepath.Join(bpDir, "bin", "detect"))
+			h.AssertPathDoesNotExist(t, filepath.Join(scratchDir, "other_buildpack"))
+		})
+
+		it("should fail if no tarball contains the buildpack", func() {
+			_, err := store.Lookup("missing/buildpack", "1.2.3")
+			h.AssertError(t, err, "buildpack 'missing/buildpack@1.2.3' not found")
+		})
+	})
+
+	when("OCILayoutBuildpackStore", func() {
+		var (
+			store     *lifecycle.OCILayoutBuildpackStore
+			layoutDir string
+		)
+
+		// writeLayout writes a layout holding a buildpackage image of some/buildpack@1.2.3 with a layer of files.
+		writeLayout := func(files map[string]string) {
+			t.Helper()
+			layerPath := filepath.Join(tmpDir, "layer.tar")
+			writeTarball(t, layerPath, false, files)
+			layer, err := tarball.LayerFromFile(layerPath)
+			h.AssertNil(t, err)
+			diffID, err := layer.DiffID()
+			h.AssertNil(t, err)
+
+			img, err := mutate.AppendLayers(empty.Image, layer)
+			h.AssertNil(t, err)
+			img, err = mutate.Config(img, v1.Config{Labels: map[string]string{
+				lifecycle.BuildpackLayersLabel: fmt.Sprintf(`{"some/buildpack":{"1.2.3":{"api":"0.4","layerDiffID":"%s"}}}`, diffID),
+			}})
+			h.AssertNil(t, err)
+			path, err := layout.Write(layoutDir, empty.Index)
+			h.AssertNil(t, err)
+			h.AssertNil(t, path.AppendImage(img))
+		}
+
+		it.Before(func() {
+			layoutDir = filepath.Join(tmpDir, "layout")
+			writeLayout(map[string]string{
+				"/cnb/buildpacks/some_buildpack/1.2.3/buildpack.toml": bpTOML("some/buildpack", "1.2.3"),
+				"/cnb/buildpacks/some_buildpack/1.2.3/bin/detect":     "#!/usr/bin/env bash\nexit 0\n",
+			})
+			store = &lifecycle.OCILayoutBuildpackStore{Dir: layoutDir, ScratchDir: scratchDir}
+		})
+
+		it("should extract the buildpack layer and return its buildpack", func() {
+			bp, err := store.Lookup("some/buildpack", "1.2.3")
+			h.AssertNil(t, err)
+
+			bpDir := filepath.Join(scratchDir, "cnb", "buildpacks", "some_buildpack", "1.2.3")
+			h.AssertEq(t, bp.ConfigFile().Buildpack.ID, "some/buildpack")
+			h.AssertEq(t, bp.ConfigFile().Dir, bpDir)
+			h.AssertPathExists(t, filepath.Join(bpDir, "bin", "detect"))
+		})
+
+		it("should not leave a partly extracted buildpack", func() {
+			h.AssertNil(t, os.RemoveAll(layoutDir))
+			writeLayout(map[string]string{
+				"/cnb/buildpacks/some_buildpack/1.2.3/buildpack.toml": bpTOML("some/buildpack", "1.2.3"),
+				"/cnb/buildpacks/some_buildpack/1.2.3/bin":            "not a directory",
+				"/cnb/buildpacks/some_buildpack/1.2.3/bin/detect":     "#!/usr/bin/env bash\nexit 0\n",
+			})
+
+			_, err := store.Lookup("some/buildpack", "1.2.3")
+			h.AssertError(t, err, "extracting buildpack 'some/buildpack@1.2.3'")
+			h.AssertPathDoesNotExist(t, filepath.Join(scratchDir, "cnb", "buildpacks", "some_buildpack", "1.2.3"))
+		})
+
+		it("should fail if no image contains the buildpack", func() {
+			_, err := store.Lookup("missing/buildpack", "1.2.3")
+			h.AssertError(t, err, "buildpack 'missing/buildpack@1.2.3' not found")
+		})
+	})
+}
+
+func writeTarball(t *testing.T, path string, gz bool, files map[string]string) {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for name, contents := range files {
+		h.AssertNil(t, tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0755, Size: int64(len(contents))}))
+		_, err := tw.Write([]byte(contents))
+		h.AssertNil(t, err)
+	}
+	h.AssertNil(t, tw.Close())
+
+	data := buf.Bytes()
+	if gz {
+		gzBuf := &bytes.Buffer{}
+		gzw := gzip.NewWriter(gzBuf)
+		_, err := gzw.Write(data)
+		h.AssertNil(t, err)
+		h.AssertNil(t, gzw.Close())
+		data = gzBuf.Bytes()
+	}
+	h.AssertNil(t, ioutil.WriteFile(path, data, 0644))
+}
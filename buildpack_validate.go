@@ -0,0 +1,135 @@
+package lifecycle
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// BuildpackProblem is a reason a buildpack cannot be used, reported against its buildpack.toml.
+type BuildpackProblem struct {
+	Path    string
+	Problem string
+}
+
+func (p BuildpackProblem) String() string {
+	return fmt.Sprintf("%s: %s", p.Path, p.Problem)
+}
+
+var buildpackTOMLKeys = map[string]bool{"api": true, "buildpack": true, "order": true, "stacks": true, "metadata": true}
+
+// ValidateBuildpack checks the buildpack.toml of bp against the buildpack spec and checks that the
+// buildpack supports stackID. The stack is not checked if stackID is empty.
+func ValidateBuildpack(bp *BuildpackTOML, stackID string) []BuildpackProblem {
+	path := filepath.Join(bp.Dir, "buildpack.toml")
+	var problems []string
+
+	var raw map[string]interface{}
+	if _, err := toml.DecodeFile(path, &raw); err != nil {
+		return []BuildpackProblem{{Path: path, Problem: err.Error()}}
+	}
+	var unknown []string
+	for key := range raw {
+		if !buildpackTOMLKeys[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	for _, key := range unknown {
+		problems = append(problems, fmt.Sprintf("unknown key '%s'", key))
+	}
+
+	if bp.API == "" {
+		problems = append(problems, "missing 'api'")
+	}
+	if bp.Buildpack.ID == "" {
+		problems = append(problems, "missing 'buildpack.id'")
+	}
+	if bp.Buildpack.Version == "" {
+		problems = append(problems, "missing 'buildpack.version'")
+	}
+
+	if len(bp.Order) > 0 {
+		for _, bin := range []string{"detect", "build"} {
+			if hasBin(bp.Dir, bin) {
+				problems = append(problems, fmt.Sprintf("'order' must not be combined with 'bin/%s'", bin))
+			}
+		}
+		if len(bp.Stacks) > 0 {
+			problems = append(problems, "'order' must not be combined with '[[stacks]]'")
+		}
+	} else {
+		for _, bin := range []string{"detect", "build"} {
+			if !hasBin(bp.Dir, bin) {
+				problems = append(problems, fmt.Sprintf("missing 'bin/%s'", bin))
+			}
+		}
+		if len(bp.Stacks) == 0 {
+			problems = append(problems, "missing '[[stacks]]'")
+		} else if stackID != "" && !bp.supportsStack(stackID) {
+			var ids []string
+			for _, stack := range bp.Stacks {
+				ids = append(ids, stack.ID)
+			}
+			problems = append(problems, fmt.Sprintf("stack '%s' is not one of the supported stacks: %s", stackID, strings.Join(ids, ", ")))
+		}
+	}
+
+	var out []BuildpackProblem
+	for _, problem := range problems {
+		out = append(out, BuildpackProblem{Path: path, Problem: problem})
+	}
+	return out
+}
+
+// ValidateOrder validates every buildpack in order, including the buildpacks in the orders of order-containing buildpacks.
+// Each buildpack is validated once.
+func ValidateOrder(order BuildpackOrder, store BuildpackStore, stackID string) ([]BuildpackProblem, error) {
+	var problems []BuildpackProblem
+	if err := validateOrder(order, store, stackID, map[string]bool{}, &problems); err != nil {
+		return nil, err
+	}
+	return problems, nil
+}
+
+func validateOrder(order BuildpackOrder, store BuildpackStore, stackID string, seen map[string]bool, problems *[]BuildpackProblem) error {
+	for _, group := range order {
+		for _, bp := range group.Group {
+			if seen[bp.String()] {
+				continue
+			}
+			seen[bp.String()] = true
+			info, err := bp.LookupIn(store)
+			if err != nil {
+				return err
+			}
+			*problems = append(*problems, ValidateBuildpack(info, stackID)...)
+			if err := validateOrder(info.Order, store, stackID, seen, problems); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (b *BuildpackTOML) supportsStack(stackID string) bool {
+	for _, stack := range b.Stacks {
+		if stack.ID == stackID || stack.ID == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+func hasBin(dir, name string) bool {
+	for _, ext := range []string{"", ".bat", ".exe"} {
+		if fi, err := os.Stat(filepath.Join(dir, "bin", name+ext)); err == nil && !fi.IsDir() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,112 @@
+package lifecycle_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestBuildpackValidate(t *testing.T) {
+	spec.Run(t, "BuildpackValidate", testBuildpackValidate, spec.Report(report.Terminal{}))
+}
+
+func testBuildpackValidate(t *testing.T, when spec.G, it spec.S) {
+	var (
+		buildpacksDir string
+		store         *lifecycle.DirBuildpackStore
+	)
+
+	it.Before(func() {
+		var err error
+		buildpacksDir, err = ioutil.TempDir("", "lifecycle.buildpack-validate")
+		h.AssertNil(t, err)
+		store = &lifecycle.DirBuildpackStore{Dir: buildpacksDir}
+	})
+
+	it.After(func() {
+		os.RemoveAll(buildpacksDir)
+	})
+
+	mkbp := func(id, toml string, bins ...string) string {
+		t.Helper()
+		dir := filepath.Join(buildpacksDir, id, "v1")
+		h.Mkdir(t, filepath.Join(dir, "bin"))
+		h.Mkfile(t, "api = \"0.4\"\n[buildpack]\nid = \""+id+"\"\nversion = \"v1\"\n"+toml, filepath.Join(dir, "buildpack.toml"))
+		for _, bin := range bins {
+			h.Mkfile(t, "", filepath.Join(dir, "bin", bin))
+		}
+		return filepath.Join(dir, "buildpack.toml")
+	}
+
+	validate := func(stackID string, ids ...string) []lifecycle.BuildpackProblem {
+		t.Helper()
+		var group []lifecycle.GroupBuildpack
+		for _, id := range ids {
+			group = append(group, lifecycle.GroupBuildpack{ID: id, Version: "v1"})
+		}
+		problems, err := lifecycle.ValidateOrder(lifecycle.BuildpackOrder{{Group: group}}, store, stackID)
+		h.AssertNil(t, err)
+		return problems
+	}
+
+	when("#ValidateOrder", func() {
+		it("should accept buildpacks that support the stack", func() {
+			mkbp("A", "[[stacks]]\nid = \"some.stack\"\n[[stacks]]\nid = \"other.stack\"\n", "detect", "build")
+			mkbp("B", "[[stacks]]\nid = \"*\"\n[metadata]\nkey = \"value\"\n", "detect", "build")
+
+			if problems := validate("other.stack", "A", "B"); len(problems) != 0 {
+				t.Fatalf("Unexpected problems:\n%+v\n", problems)
+			}
+		})
+
+		it("should report each problem with the path of the buildpack", func() {
+			pathA := mkbp("A", "[unknown]\nkey = true\n", "detect")
+			pathB := mkbp("B", "[[stacks]]\nid = \"some.stack\"\n", "detect", "build")
+
+			if s := cmp.Diff(validate("other.stack", "A", "B"), []lifecycle.BuildpackProblem{
+				{Path: pathA, Problem: "unknown key 'unknown'"},
+				{Path: pathA, Problem: "missing 'bin/build'"},
+				{Path: pathA, Problem: "missing '[[stacks]]'"},
+				{Path: pathB, Problem: "stack 'other.stack' is not one of the supported stacks: some.stack"},
+			}); s != "" {
+				t.Fatalf("Unexpected problems:\n%s\n", s)
+			}
+		})
+
+		it("should not check the stack if no stack ID is given", func() {
+			mkbp("A", "[[stacks]]\nid = \"some.stack\"\n", "detect", "build")
+
+			if problems := validate("", "A"); len(problems) != 0 {
+				t.Fatalf("Unexpected problems:\n%+v\n", problems)
+			}
+		})
+
+		it("should validate the buildpacks in orders once each", func() {
+			pathA := mkbp("A", "", "detect", "build")
+			pathM := mkbp("M", "[[stacks]]\nid = \"some.stack\"\n[[order]]\ngroup = [{id = \"A\", version = \"v1\"}]\n", "detect")
+
+			if s := cmp.Diff(validate("some.stack", "M", "A"), []lifecycle.BuildpackProblem{
+				{Path: pathM, Problem: "'order' must not be combined with 'bin/detect'"},
+				{Path: pathM, Problem: "'order' must not be combined with '[[stacks]]'"},
+				{Path: pathA, Problem: "missing '[[stacks]]'"},
+			}); s != "" {
+				t.Fatalf("Unexpected problems:\n%s\n", s)
+			}
+		})
+
+		it("should fail if a buildpack cannot be found", func() {
+			_, err := lifecycle.ValidateOrder(lifecycle.BuildpackOrder{
+				{Group: []lifecycle.GroupBuildpack{{ID: "missing", Version: "v1"}}},
+			}, store, "some.stack")
+			h.AssertNotNil(t, err)
+		})
+	})
+}
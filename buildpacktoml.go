@@ -7,6 +7,8 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 
@@ -22,7 +24,10 @@ type DirBuildpackStore struct {
 
 func (f *DirBuildpackStore) Lookup(bpID, bpVersion string) (Buildpack, error) {
 	bpTOML := BuildpackTOML{}
-	bpPath := filepath.Join(f.Dir, launch.EscapeID(bpID), bpVersion)
+	bpPath, err := filepath.Abs(filepath.Join(f.Dir, launch.EscapeID(bpID), bpVersion))
+	if err != nil {
+		return nil, err
+	}
 	tomlPath := filepath.Join(bpPath, "buildpack.toml")
 	if _, err := toml.DecodeFile(tomlPath, &bpTOML); err != nil {
 		return nil, err
@@ -51,13 +56,23 @@ type BuildpackTOML struct {
 	API       string         `toml:"api"`
 	Buildpack BuildpackInfo  `toml:"buildpack"`
 	Order     BuildpackOrder `toml:"order"`
+	Stacks    []Stack        `toml:"stacks"`
 	Dir       string         `toml:"-"`
 }
 
+type Stack struct {
+	ID     string   `toml:"id"`
+	Mixins []string `toml:"mixins,omitempty"`
+}
+
 func (b *BuildpackTOML) String() string {
 	return b.Buildpack.Name + " " + b.Buildpack.Version
 }
 
+func (b *BuildpackTOML) ConfigFile() *BuildpackTOML {
+	return b
+}
+
 func (b *BuildpackTOML) Build(bpPlan BuildpackPlan, config BuildConfig) (BuildResult, error) {
 	if api.MustParse(b.API).Equal(api.MustParse("0.2")) {
 		for i := range bpPlan.Entries {
@@ -76,15 +91,19 @@ func (b *BuildpackTOML) Build(bpPlan BuildpackPlan, config BuildConfig) (BuildRe
 		return BuildResult{}, err
 	}
 
-	if err := b.runBuildCmd(bpLayersDir, bpPlanPath, config); err != nil {
-		return BuildResult{}, err
+	// stats are returned even if the buildpack fails, as failed builds are the ones worth measuring
+	stats, err := b.runBuildCmd(bpLayersDir, bpPlanPath, config)
+	if err != nil {
+		return BuildResult{Stats: stats}, err
 	}
 
 	if err := b.setupEnv(config.Env, bpLayersDir); err != nil {
-		return BuildResult{}, err
+		return BuildResult{Stats: stats}, err
 	}
 
-	return b.readOutputFiles(bpLayersDir, bpPlanPath, bpPlan)
+	br, err := b.readOutputFiles(bpLayersDir, bpPlanPath, bpPlan, config.logger())
+	br.Stats = stats
+	return br, err
 }
 
 func preparePaths(bpID string, bpPlan BuildpackPlan, layersDir, planDir string) (string, string, error) {
@@ -105,7 +124,7 @@ func preparePaths(bpID string, bpPlan BuildpackPlan, layersDir, planDir string)
 	return bpLayersDir, bpPlanPath, nil
 }
 
-func (b *BuildpackTOML) runBuildCmd(bpLayersDir, bpPlanPath string, config BuildConfig) error {
+func (b *BuildpackTOML) runBuildCmd(bpLayersDir, bpPlanPath string, config BuildConfig) (BuildpackStats, error) {
 	cmd := exec.Command(
 		filepath.Join(b.Dir, "bin", "build"),
 		bpLayersDir,
@@ -113,8 +132,6 @@ func (b *BuildpackTOML) runBuildCmd(bpLayersDir, bpPlanPath string, config Build
 		bpPlanPath,
 	)
 	cmd.Dir = config.AppDir
-	cmd.Stdout = config.Out
-	cmd.Stderr = config.Err
 
 	var err error
 	if b.Buildpack.ClearEnv {
@@ -122,15 +139,85 @@ func (b *BuildpackTOML) runBuildCmd(bpLayersDir, bpPlanPath string, config Build
 	} else {
 		cmd.Env, err = config.Env.WithPlatform(config.PlatformDir)
 		if err != nil {
-			return err
+			return BuildpackStats{}, err
+		}
+		if config.EnvPolicy != nil {
+			cmd.Env, err = config.EnvPolicy.apply(b.Buildpack.ID, cmd.Env, config.Env.List(), config.PlatformDir)
+			if err != nil {
+				return BuildpackStats{}, err
+			}
 		}
 	}
 	cmd.Env = append(cmd.Env, EnvBuildpackDir+"="+b.Dir)
 
-	if err := cmd.Run(); err != nil {
-		return NewLifecycleError(err, ErrTypeBuildpack)
+	out, errOut := config.Out, config.Err
+	if out == nil {
+		out = ioutil.Discard
 	}
-	return nil
+	if errOut == nil {
+		errOut = ioutil.Discard
+	}
+	stdout, err := newOutputPipe(out)
+	if err != nil {
+		return BuildpackStats{}, err
+	}
+	stderr, err := newOutputPipe(errOut)
+	if err != nil {
+		stdout.wait()
+		return BuildpackStats{}, err
+	}
+	cmd.Stdout, cmd.Stderr = stdout.w, stderr.w
+
+	// layers restored from the cache and left untouched by the buildpack don't count as written
+	sizeBefore, sizeErr := dirSize(bpLayersDir)
+	start := time.Now()
+	err = cmd.Start()
+	stdout.started()
+	stderr.started()
+	if err == nil {
+		err = cmd.Wait()
+	}
+	stats := BuildpackStats{DurationMS: time.Since(start).Milliseconds()}
+	for _, p := range []*outputPipe{stdout, stderr} {
+		if outErr := p.wait(); err == nil && outErr != nil {
+			err = outErr
+		}
+	}
+	if cmd.ProcessState != nil {
+		stats.ExitCode = cmd.ProcessState.ExitCode()
+		stats.MaxRSS = maxRSS(cmd.ProcessState)
+	}
+	if err != nil {
+		return stats, NewLifecycleError(err, ErrTypeBuildpack)
+	}
+	sizeAfter, err := dirSize(bpLayersDir)
+	if sizeErr != nil {
+		err = sizeErr
+	}
+	if err != nil {
+		config.logger().Warnf("Warning: failed to measure the layers written by %s: %s", b.Buildpack.ID, err)
+	} else if sizeAfter > sizeBefore {
+		stats.LayersBytes = sizeAfter - sizeBefore
+	}
+	return stats, nil
+}
+
+// dirSize sums the sizes of the regular files in dir, or returns 0 if dir doesn't exist.
+func dirSize(dir string) (int64, error) {
+	var total int64
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if fi.Mode().IsRegular() {
+			total += fi.Size()
+		}
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	return total, err
 }
 
 func (b *BuildpackTOML) setupEnv(buildEnv BuildEnv, layersDir string) error {
@@ -181,7 +268,7 @@ func isBuild(path string) bool {
 	return err == nil && layerTOML.Build
 }
 
-func (b *BuildpackTOML) readOutputFiles(bpLayersDir, bpPlanPath string, bpPlanIn BuildpackPlan) (BuildResult, error) {
+func (b *BuildpackTOML) readOutputFiles(bpLayersDir, bpPlanPath string, bpPlanIn BuildpackPlan, logger Logger) (BuildResult, error) {
 	br := BuildResult{}
 	bpFromBpInfo := GroupBuildpack{ID: b.Buildpack.ID, Version: b.Buildpack.Version}
 
@@ -243,6 +330,19 @@ func (b *BuildpackTOML) readOutputFiles(bpLayersDir, bpPlanPath string, bpPlanIn
 		br.BOM = withBuildpack(bpFromBpInfo, launchTOML.BOM)
 	}
 
+	// buildpacks written for older APIs were not held to these rules, so they are only warned
+	if problems := validateLaunch(launchTOML); len(problems) > 0 {
+		if api.MustParse(b.API).Compare(api.MustParse("0.5")) < 0 { // buildpack API <= 0.4
+			logger.Warnf("Warning: buildpack '%s' wrote an invalid launch.toml, which fails the build from buildpack API 0.5: %s",
+				bpFromBpInfo, strings.Join(problems, "; "))
+		} else {
+			return BuildResult{}, NewLifecycleError(
+				fmt.Errorf("buildpack '%s' wrote an invalid launch.toml: %s", bpFromBpInfo, strings.Join(problems, "; ")),
+				ErrTypeBuildpack,
+			)
+		}
+	}
+
 	// set data from launch.toml
 	br.Labels = append([]Label{}, launchTOML.Labels...)
 	for i := range launchTOML.Processes {
@@ -274,6 +374,40 @@ func validateBOM(bom []BOMEntry, bpAPI string) error {
 	return nil
 }
 
+// reservedLabelPrefix is the label namespace reserved for the lifecycle and platforms.
+const reservedLabelPrefix = "io.buildpacks."
+
+func validateLaunch(launchTOML LaunchTOML) []string {
+	var problems []string
+	types := map[string]bool{}
+	for i, proc := range launchTOML.Processes {
+		if proc.Type == "" {
+			problems = append(problems, fmt.Sprintf("processes[%d] has an empty type", i))
+		} else if types[proc.Type] {
+			problems = append(problems, fmt.Sprintf("process type '%s' is defined more than once", proc.Type))
+		}
+		types[proc.Type] = true
+		if proc.Command == "" {
+			problems = append(problems, fmt.Sprintf("processes[%d] has an empty command", i))
+		}
+	}
+	for _, label := range launchTOML.Labels {
+		if strings.HasPrefix(label.Key, reservedLabelPrefix) {
+			problems = append(problems, fmt.Sprintf("label '%s' is in the reserved '%s*' namespace", label.Key, reservedLabelPrefix))
+		}
+	}
+	for _, slice := range launchTOML.Slices {
+		for _, path := range slice.Paths {
+			clean := filepath.ToSlash(filepath.Clean(path))
+			if filepath.IsAbs(path) || filepath.VolumeName(path) != "" ||
+				strings.HasPrefix(clean, "/") || clean == ".." || strings.HasPrefix(clean, "../") {
+				problems = append(problems, fmt.Sprintf("slice path '%s' is outside of the app directory", path))
+			}
+		}
+	}
+	return problems
+}
+
 func validateUnmet(unmet []Unmet, bpPlan BuildpackPlan) error {
 	for _, unmet := range unmet {
 		if unmet.Name == "" {
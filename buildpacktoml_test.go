@@ -7,13 +7,18 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/BurntSushi/toml"
 
+	"github.com/apex/log"
+	"github.com/apex/log/handlers/memory"
 	"github.com/golang/mock/gomock"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
 
@@ -162,6 +167,49 @@ func testBuildpackTOML(t *testing.T, when spec.G, it spec.S) {
 				}
 			})
 
+			it("should measure the build", func() {
+				launchTOML := "[[labels]]\nkey = \"some-key\"\nvalue = \"some-value\"\n"
+				h.Mkfile(t, launchTOML, filepath.Join(appDir, "launch-A-v1.toml"))
+				h.Mkdir(t, filepath.Join(layersDir, "A", "restored-layer"))
+				restoredFile := filepath.Join(layersDir, "A", "restored-layer", "some-file")
+				h.Mkfile(t, "some-restored-data", restoredFile)
+				past := time.Now().Add(-time.Hour)
+				h.AssertNil(t, os.Chtimes(restoredFile, past, past))
+				h.Mkdir(t, filepath.Join(appDir, "layers-A-v1", "extracted-layer"))
+				extractedFile := filepath.Join(appDir, "layers-A-v1", "extracted-layer", "some-file")
+				h.Mkfile(t, "some-extracted-data", extractedFile)
+				h.AssertNil(t, os.Chtimes(extractedFile, past, past))
+
+				br, err := bpTOML.Build(lifecycle.BuildpackPlan{}, config)
+				if err != nil {
+					t.Fatalf("Unexpected error:\n%s\n", err)
+				}
+				h.AssertEq(t, br.Stats.ExitCode, 0)
+				h.AssertEq(t, br.Stats.LayersBytes, int64(len(launchTOML)+len("some-extracted-data")))
+				if br.Stats.DurationMS < 0 {
+					t.Fatalf("Unexpected duration: %d", br.Stats.DurationMS)
+				}
+				if runtime.GOOS != "windows" && br.Stats.MaxRSS <= 0 {
+					t.Fatalf("Expected peak RSS to be recorded")
+				}
+			})
+
+			it("should not wait for background processes that hold its output open", func() {
+				h.SkipIf(t, runtime.GOOS == "windows", "background processes are not started on Windows")
+				h.Mkfile(t, "10", filepath.Join(appDir, "build-background-A-v1"))
+
+				start := time.Now()
+				if _, err := bpTOML.Build(lifecycle.BuildpackPlan{}, config); err != nil {
+					t.Fatalf("Unexpected error:\n%s\n", err)
+				}
+				if elapsed := time.Since(start); elapsed > 5*time.Second {
+					t.Fatalf("Build waited %s for a background process", elapsed)
+				}
+				if s := cmp.Diff(h.CleanEndings(stdout.String()), "build out: A@v1\n"); s != "" {
+					t.Fatalf("Unexpected stdout:\n%s\n", s)
+				}
+			})
+
 			it("should connect stdout and stdin to the terminal", func() {
 				if _, err := bpTOML.Build(lifecycle.BuildpackPlan{}, config); err != nil {
 					t.Fatalf("Unexpected error:\n%s\n", err)
@@ -174,6 +222,21 @@ func testBuildpackTOML(t *testing.T, when spec.G, it spec.S) {
 				}
 			})
 
+			it("should give raw output to the terminal as is", func() {
+				h.SkipIf(t, runtime.GOOS != "linux", "file descriptors are read from /proc")
+				terminal, err := os.Create(filepath.Join(tmpDir, "terminal"))
+				h.AssertNil(t, err)
+				defer terminal.Close()
+				config.Out = terminal
+				h.Mkfile(t, "", filepath.Join(appDir, "build-stdout-A-v1"))
+
+				if _, err := bpTOML.Build(lifecycle.BuildpackPlan{}, config); err != nil {
+					t.Fatalf("Unexpected error:\n%s\n", err)
+				}
+				h.AssertEq(t, h.Rdfile(t, filepath.Join(appDir, "build-stdout-A-v1")), terminal.Name()+"\n")
+				h.AssertEq(t, h.Rdfile(t, terminal.Name()), "build out: A@v1\n")
+			})
+
 			when("build result", func() {
 				it("should get bom entries from launch.toml and unmet requires from build.toml", func() {
 					bpPlan := lifecycle.BuildpackPlan{
@@ -251,7 +314,7 @@ func testBuildpackTOML(t *testing.T, when spec.G, it spec.S) {
 						MetRequires: []string{"some-deprecated-bp-replace-version-dep", "some-dep", "some-replace-version-dep"},
 						Processes:   []launch.Process{},
 						Slices:      []layers.Slice{},
-					}); s != "" {
+					}, cmpopts.IgnoreFields(lifecycle.BuildResult{}, "Stats")); s != "" {
 						t.Fatalf("Unexpected:\n%s\n", s)
 					}
 				})
@@ -281,7 +344,7 @@ func testBuildpackTOML(t *testing.T, when spec.G, it spec.S) {
 						MetRequires: nil,
 						Processes:   []launch.Process{},
 						Slices:      []layers.Slice{},
-					}); s != "" {
+					}, cmpopts.IgnoreFields(lifecycle.BuildResult{}, "Stats")); s != "" {
 						t.Fatalf("Unexpected:\n%s\n", s)
 					}
 				})
@@ -309,7 +372,7 @@ func testBuildpackTOML(t *testing.T, when spec.G, it spec.S) {
 							{Type: "other-type", Command: "other-cmd", BuildpackID: "A"},
 						},
 						Slices: []layers.Slice{},
-					}); s != "" {
+					}, cmpopts.IgnoreFields(lifecycle.BuildResult{}, "Stats")); s != "" {
 						t.Fatalf("Unexpected metadata:\n%s\n", s)
 					}
 				})
@@ -332,7 +395,7 @@ func testBuildpackTOML(t *testing.T, when spec.G, it spec.S) {
 						MetRequires: nil,
 						Processes:   []launch.Process{},
 						Slices:      []layers.Slice{{Paths: []string{"some-path", "some-other-path"}}},
-					}); s != "" {
+					}, cmpopts.IgnoreFields(lifecycle.BuildResult{}, "Stats")); s != "" {
 						t.Fatalf("Unexpected:\n%s\n", s)
 					}
 				})
@@ -371,6 +434,47 @@ func testBuildpackTOML(t *testing.T, when spec.G, it spec.S) {
 			})
 		})
 
+		when("building with an env policy", func() {
+			it.Before(func() {
+				h.Mkfile(t, "Av1", filepath.Join(platformDir, "env", "TEST_ENV"))
+				mockEnv.EXPECT().WithPlatform(platformDir).Return(append(os.Environ(), "TEST_ENV=Av1"), nil)
+				mockEnv.EXPECT().List().Return(append(os.Environ(), "TEST_ENV=base")).AnyTimes()
+			})
+
+			assertTestEnv := func(expected string) {
+				t.Helper()
+				if _, err := bpTOML.Build(lifecycle.BuildpackPlan{}, config); err != nil {
+					t.Fatalf("Unexpected error:\n%s\n", err)
+				}
+				if s := cmp.Diff(h.Rdfile(t, filepath.Join(appDir, "build-info-A-v1")), "TEST_ENV: "+expected+"\n"); s != "" {
+					t.Fatalf("Unexpected info:\n%s\n", s)
+				}
+			}
+
+			it("should not provide denied platform env vars", func() {
+				config.EnvPolicy = &lifecycle.EnvPolicy{Buildpacks: []lifecycle.EnvPolicyRule{{ID: "A", Deny: []string{"TEST_ENV"}}}}
+				assertTestEnv("base")
+			})
+
+			it("should only provide allowed platform env vars", func() {
+				config.EnvPolicy = &lifecycle.EnvPolicy{Buildpacks: []lifecycle.EnvPolicyRule{{ID: "*", Allow: []string{"OTHER_ENV"}}}}
+				assertTestEnv("base")
+			})
+
+			it("should prefer the rule for the buildpack to the wildcard rule", func() {
+				config.EnvPolicy = &lifecycle.EnvPolicy{Buildpacks: []lifecycle.EnvPolicyRule{
+					{ID: "*", Deny: []string{"TEST_ENV"}},
+					{ID: "A", Allow: []string{"TEST_ENV"}},
+				}}
+				assertTestEnv("Av1")
+			})
+
+			it("should provide platform env vars if no rule applies", func() {
+				config.EnvPolicy = &lifecycle.EnvPolicy{Buildpacks: []lifecycle.EnvPolicyRule{{ID: "B", Deny: []string{"TEST_ENV"}}}}
+				assertTestEnv("Av1")
+			})
+		})
+
 		when("building fails", func() {
 			it("should error when layer directories cannot be created", func() {
 				h.Mkfile(t, "some-data", filepath.Join(layersDir, "A"))
@@ -415,6 +519,17 @@ func testBuildpackTOML(t *testing.T, when spec.G, it spec.S) {
 				}
 			})
 
+			it("should return the stats of the command when it fails", func() {
+				mockEnv.EXPECT().WithPlatform(platformDir).Return(append(os.Environ(), "TEST_ENV=Av1"), nil)
+				h.Mkfile(t, "42", filepath.Join(appDir, "build-status-A-v1"))
+
+				br, err := bpTOML.Build(lifecycle.BuildpackPlan{}, config)
+				if err, ok := err.(*lifecycle.Error); !ok || err.Type != lifecycle.ErrTypeBuildpack {
+					t.Fatalf("Incorrect error: %s\n", err)
+				}
+				h.AssertEq(t, br.Stats.ExitCode, 42)
+			})
+
 			when("modifying the env fails", func() {
 				var appendErr error
 
@@ -493,6 +608,70 @@ func testBuildpackTOML(t *testing.T, when spec.G, it spec.S) {
 				h.AssertStringContains(t, err.Error(), expected)
 			})
 
+			when("launch.toml is invalid", func() {
+				it("should error naming the buildpack and each problem", func() {
+					mockEnv.EXPECT().WithPlatform(platformDir).Return(append(os.Environ(), "TEST_ENV=Av1"), nil)
+					h.Mkfile(t,
+						"[[processes]]\n"+
+							`type = "web"`+"\n"+
+							`command = "some-cmd"`+"\n"+
+							"[[processes]]\n"+
+							`type = "web"`+"\n"+
+							`command = "other-cmd"`+"\n"+
+							"[[processes]]\n"+
+							`type = ""`+"\n"+
+							`command = ""`+"\n"+
+							"[[labels]]\n"+
+							`key = "io.buildpacks.build.metadata"`+"\n"+
+							`value = "some-value"`+"\n"+
+							"[[slices]]\n"+
+							`paths = ["some-dir/*", "../outside", "/abs", "some-dir/../.."]`+"\n",
+						filepath.Join(appDir, "launch-A-v1.toml"),
+					)
+					_, err := bpTOML.Build(lifecycle.BuildpackPlan{}, config)
+					if err, ok := err.(*lifecycle.Error); !ok || err.Type != lifecycle.ErrTypeBuildpack {
+						t.Fatalf("Incorrect error: %s\n", err)
+					}
+					h.AssertError(t, err, "buildpack 'A@v1' wrote an invalid launch.toml: "+
+						"process type 'web' is defined more than once; "+
+						"processes[2] has an empty type; "+
+						"processes[2] has an empty command; "+
+						"label 'io.buildpacks.build.metadata' is in the reserved 'io.buildpacks.*' namespace; "+
+						"slice path '../outside' is outside of the app directory; "+
+						"slice path '/abs' is outside of the app directory; "+
+						"slice path 'some-dir/../..' is outside of the app directory")
+				})
+			})
+
+			when("launch.toml is invalid for an older buildpack API", func() {
+				it("should warn naming the buildpack and each problem", func() {
+					logHandler := memory.New()
+					config.Logger = &log.Logger{Handler: logHandler}
+					bpTOML.API = "0.4"
+					mockEnv.EXPECT().WithPlatform(platformDir).Return(append(os.Environ(), "TEST_ENV=Av1"), nil)
+					h.Mkfile(t,
+						"[[processes]]\n"+
+							`type = "web"`+"\n"+
+							`command = "some-cmd"`+"\n"+
+							"[[processes]]\n"+
+							`type = "web"`+"\n"+
+							`command = "other-cmd"`+"\n"+
+							"[[labels]]\n"+
+							`key = "io.buildpacks.build.metadata"`+"\n"+
+							`value = "some-value"`+"\n",
+						filepath.Join(appDir, "launch-A-v1.toml"),
+					)
+					br, err := bpTOML.Build(lifecycle.BuildpackPlan{}, config)
+					h.AssertNil(t, err)
+					h.AssertEq(t, len(br.Processes), 2)
+					h.AssertEq(t, len(logHandler.Entries), 1)
+					h.AssertEq(t, logHandler.Entries[0].Message, "Warning: buildpack 'A@v1' wrote an invalid launch.toml, "+
+						"which fails the build from buildpack API 0.5: "+
+						"process type 'web' is defined more than once; "+
+						"label 'io.buildpacks.build.metadata' is in the reserved 'io.buildpacks.*' namespace")
+				})
+			})
+
 			when("invalid unmet entries", func() {
 				when("missing name", func() {
 					it("should error", func() {
@@ -684,7 +863,7 @@ func testBuildpackTOML(t *testing.T, when spec.G, it spec.S) {
 						},
 						Processes: nil,
 						Slices:    nil,
-					}); s != "" {
+					}, cmpopts.IgnoreFields(lifecycle.BuildResult{}, "Stats")); s != "" {
 						t.Fatalf("Unexpected:\n%s\n", s)
 					}
 				})
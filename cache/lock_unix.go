@@ -0,0 +1,20 @@
+// +build linux darwin
+
+package cache
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// tryLock takes an exclusive advisory lock on f, reporting false if another open file holds it.
+func tryLock(f *os.File) (bool, error) {
+	if err := unix.Flock(int(f.Fd()), unix.LOCK_EX|unix.LOCK_NB); err != nil {
+		if err == unix.EWOULDBLOCK {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,19 @@
+package cache
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+// tryLock takes an exclusive lock on f, reporting false if another open file holds it.
+func tryLock(f *os.File) (bool, error) {
+	flags := uint32(windows.LOCKFILE_EXCLUSIVE_LOCK | windows.LOCKFILE_FAIL_IMMEDIATELY)
+	if err := windows.LockFileEx(windows.Handle(f.Fd()), flags, 0, 1, 0, &windows.Overlapped{}); err != nil {
+		if err == windows.ERROR_LOCK_VIOLATION {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
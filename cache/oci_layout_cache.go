@@ -0,0 +1,402 @@
+package cache
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	v1 "github.com/google/go-containerregistry/pkg/v1"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/match"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
+	"github.com/pkg/errors"
+
+	"github.com/buildpacks/lifecycle"
+	"github.com/buildpacks/lifecycle/archive"
+)
+
+const (
+	// OCILayoutCacheRef is the ref name of the cache image in the index of an OCI image layout.
+	OCILayoutCacheRef = "cache"
+
+	annotationRefName = "org.opencontainers.image.ref.name"
+)
+
+// OCILayoutCache stores the cache as an image in an OCI image layout directory, or in a tar archive of one,
+// with the metadata in a label like ImageCache. The layout can be copied as one artifact and inspected with
+// OCI tooling without a registry.
+type OCILayoutCache struct {
+	committed bool
+	lock      *os.File
+	archive   string
+	path      layout.Path
+	origImage v1.Image
+	layers    []v1.Layer
+	metadata  lifecycle.CacheMetadata
+}
+
+// NewOCILayoutCache returns the cache in the OCI image layout at path, failing if another build is using it.
+func NewOCILayoutCache(path string) (*OCILayoutCache, error) {
+	return NewOCILayoutCacheWithTimeout(path, 0)
+}
+
+// NewOCILayoutCacheWithTimeout returns the cache in the OCI image layout at path, creating the layout if it
+// doesn't exist, and waiting up to lockTimeout for another build to stop using it.
+// If path ends in ".tar", the layout is read from a tar archive at path, and Commit rewrites the archive.
+// The cache holds an advisory lock on path until it is committed or closed.
+func NewOCILayoutCacheWithTimeout(path string, lockTimeout time.Duration) (*OCILayoutCache, error) {
+	c := &OCILayoutCache{path: layout.Path(path)}
+	if strings.HasSuffix(path, ".tar") {
+		var err error
+		if c.lock, err = lockFile(path+".lock", "cache archive '"+path+"'", lockTimeout); err != nil {
+			return nil, err
+		}
+		dir, err := ioutil.TempDir("", "oci-layout-cache.")
+		if err != nil {
+			c.lock.Close()
+			return nil, err
+		}
+		c.archive = path
+		c.path = layout.Path(dir)
+		if err := extractLayout(path, dir); err != nil {
+			c.release()
+			return nil, errors.Wrapf(err, "extracting OCI layout archive '%s'", path)
+		}
+	} else {
+		if err := os.MkdirAll(path, 0777); err != nil {
+			return nil, errors.Wrapf(err, "creating OCI layout '%s'", path)
+		}
+		var err error
+		if c.lock, err = lockDir(path, lockTimeout); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.init(); err != nil {
+		c.release()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *OCILayoutCache) init() error {
+	path, err := layout.FromPath(string(c.path))
+	if err != nil {
+		if path, err = layout.Write(string(c.path), empty.Index); err != nil {
+			return errors.Wrapf(err, "creating OCI layout '%s'", c.Name())
+		}
+	}
+
+	idx, err := path.ImageIndex()
+	if err != nil {
+		return errors.Wrapf(err, "reading OCI layout '%s'", c.Name())
+	}
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return errors.Wrapf(err, "reading OCI layout '%s'", c.Name())
+	}
+	for _, desc := range manifest.Manifests {
+		if desc.Annotations[annotationRefName] == OCILayoutCacheRef {
+			if c.origImage, err = idx.Image(desc.Digest); err != nil {
+				return errors.Wrapf(err, "reading cache image in OCI layout '%s'", c.Name())
+			}
+		}
+	}
+	return nil
+}
+
+// Close releases the lock on the layout without committing. The cache can't be modified after Close.
+func (c *OCILayoutCache) Close() error {
+	if c.committed {
+		return nil
+	}
+	c.committed = true
+	return c.release()
+}
+
+// release removes the layout extracted from an archive and releases the lock.
+func (c *OCILayoutCache) release() error {
+	if c.archive != "" {
+		os.RemoveAll(string(c.path))
+	}
+	return c.lock.Close()
+}
+
+func (c *OCILayoutCache) Exists() bool {
+	return c.origImage != nil
+}
+
+func (c *OCILayoutCache) Name() string {
+	if c.archive != "" {
+		return c.archive
+	}
+	return string(c.path)
+}
+
+func (c *OCILayoutCache) SetMetadata(metadata lifecycle.CacheMetadata) error {
+	if c.committed {
+		return errCacheCommitted
+	}
+	c.metadata = metadata
+	return nil
+}
+
+func (c *OCILayoutCache) RetrieveMetadata() (lifecycle.CacheMetadata, error) {
+	var meta lifecycle.CacheMetadata
+	if c.origImage == nil {
+		return meta, nil
+	}
+	config, err := c.origImage.ConfigFile()
+	if err != nil {
+		return meta, errors.Wrap(err, "reading cache image config")
+	}
+	if json.Unmarshal([]byte(config.Config.Labels[MetadataLabel]), &meta) != nil {
+		return lifecycle.CacheMetadata{}, nil
+	}
+	return meta, nil
+}
+
+func (c *OCILayoutCache) AddLayerFile(tarPath string, diffID string) error {
+	if c.committed {
+		return errCacheCommitted
+	}
+	layer, err := tarball.LayerFromFile(tarPath)
+	if err != nil {
+		return errors.Wrapf(err, "caching layer (%s)", diffID)
+	}
+	if actual, err := layer.DiffID(); err != nil {
+		return errors.Wrapf(err, "caching layer (%s)", diffID)
+	} else if actual.String() != diffID {
+		return errors.Errorf("caching layer (%s): layer has SHA '%s'", diffID, actual)
+	}
+	c.layers = append(c.layers, layer)
+	return nil
+}
+
+func (c *OCILayoutCache) ReuseLayer(diffID string) error {
+	if c.committed {
+		return errCacheCommitted
+	}
+	layer, err := c.layer(diffID)
+	if err != nil {
+		return errors.Wrapf(err, "reusing layer (%s)", diffID)
+	}
+	c.layers = append(c.layers, layer)
+	return nil
+}
+
+func (c *OCILayoutCache) RetrieveLayer(diffID string) (io.ReadCloser, error) {
+	layer, err := c.layer(diffID)
+	if err != nil {
+		return nil, err
+	}
+	return layer.Uncompressed()
+}
+
+func (c *OCILayoutCache) layer(diffID string) (v1.Layer, error) {
+	if c.origImage == nil {
+		return nil, errors.Errorf("layer with SHA '%s' not found", diffID)
+	}
+	hash, err := v1.NewHash(diffID)
+	if err != nil {
+		return nil, err
+	}
+	layer, err := c.origImage.LayerByDiffID(hash)
+	if err != nil {
+		return nil, errors.Wrapf(err, "layer with SHA '%s' not found", diffID)
+	}
+	return layer, nil
+}
+
+// Commit writes the cache image to the layout, replacing the previous one, and removes the blobs that
+// no image in the layout references.
+func (c *OCILayoutCache) Commit() error {
+	if c.committed {
+		return errCacheCommitted
+	}
+	c.committed = true
+	defer c.release()
+
+	img, err := mutate.AppendLayers(empty.Image, c.layers...)
+	if err != nil {
+		return errors.Wrap(err, "creating cache image")
+	}
+	config, err := img.ConfigFile()
+	if err != nil {
+		return errors.Wrap(err, "creating cache image")
+	}
+	data, err := json.Marshal(c.metadata)
+	if err != nil {
+		return errors.Wrap(err, "serializing metadata")
+	}
+	config = config.DeepCopy()
+	config.Config.Labels = map[string]string{MetadataLabel: string(data)}
+	if img, err = mutate.ConfigFile(img, config); err != nil {
+		return errors.Wrap(err, "creating cache image")
+	}
+
+	if err := c.path.ReplaceImage(img, match.Name(OCILayoutCacheRef), layout.WithAnnotations(map[string]string{annotationRefName: OCILayoutCacheRef})); err != nil {
+		return errors.Wrapf(err, "writing cache image to OCI layout '%s'", c.Name())
+	}
+	c.origImage = img
+	if _, err := c.prune(); err != nil {
+		return errors.Wrap(err, "removing unreferenced blobs")
+	}
+	if c.archive != "" {
+		return c.writeArchive()
+	}
+	return nil
+}
+
+// Prune removes the blobs in the layout that no image or index in the layout references,
+// rewriting the archive of the layout if there is one.
+func (c *OCILayoutCache) Prune() (PruneReport, error) {
+	if c.committed {
+		return PruneReport{}, errCacheCommitted
+	}
+	report, err := c.prune()
+	if err != nil || c.archive == "" || report.RemovedLayers == 0 {
+		return report, err
+	}
+	return report, c.writeArchive()
+}
+
+func (c *OCILayoutCache) prune() (PruneReport, error) {
+	var report PruneReport
+	idx, err := c.path.ImageIndex()
+	if err != nil {
+		return report, err
+	}
+	referenced := map[v1.Hash]bool{}
+	if err := addReferencedBlobs(idx, referenced); err != nil {
+		return report, err
+	}
+
+	blobsDir := filepath.Join(string(c.path), "blobs", "sha256")
+	fis, err := ioutil.ReadDir(blobsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return report, err
+	}
+	for _, fi := range fis {
+		if referenced[v1.Hash{Algorithm: "sha256", Hex: fi.Name()}] {
+			report.Layers++
+			report.Bytes += fi.Size()
+			continue
+		}
+		if err := os.Remove(filepath.Join(blobsDir, fi.Name())); err != nil {
+			return report, errors.Wrapf(err, "removing blob '%s'", fi.Name())
+		}
+		report.RemovedLayers++
+		report.ReclaimedBytes += fi.Size()
+	}
+	return report, nil
+}
+
+func addReferencedBlobs(idx v1.ImageIndex, referenced map[v1.Hash]bool) error {
+	manifest, err := idx.IndexManifest()
+	if err != nil {
+		return err
+	}
+	for _, desc := range manifest.Manifests {
+		referenced[desc.Digest] = true
+		if desc.MediaType.IsIndex() {
+			child, err := idx.ImageIndex(desc.Digest)
+			if err != nil {
+				return err
+			}
+			if err := addReferencedBlobs(child, referenced); err != nil {
+				return err
+			}
+			continue
+		}
+		if !desc.MediaType.IsImage() {
+			continue
+		}
+		img, err := idx.Image(desc.Digest)
+		if err != nil {
+			return err
+		}
+		imgManifest, err := img.Manifest()
+		if err != nil {
+			return err
+		}
+		referenced[imgManifest.Config.Digest] = true
+		for _, layer := range imgManifest.Layers {
+			referenced[layer.Digest] = true
+		}
+	}
+	return nil
+}
+
+// extractLayout extracts the OCI layout in the tar archive at path to dir. The layout is empty if there is no archive.
+func extractLayout(path, dir string) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+	tr := archive.NewNormalizingTarReader(tar.NewReader(f))
+	tr.PrependDir(dir)
+	return archive.Extract(tr)
+}
+
+// writeArchive replaces the archive with a tar archive of the layout. The archive is written to a temporary file
+// and renamed into place, so an interrupted write leaves the previous archive.
+func (c *OCILayoutCache) writeArchive() error {
+	tmp, err := ioutil.TempFile(filepath.Dir(c.archive), filepath.Base(c.archive)+".")
+	if err != nil {
+		return errors.Wrapf(err, "writing OCI layout archive '%s'", c.archive)
+	}
+	defer os.Remove(tmp.Name())
+
+	tw := tar.NewWriter(tmp)
+	err = filepath.Walk(string(c.path), func(path string, fi os.FileInfo, err error) error {
+		if err != nil || path == string(c.path) {
+			return err
+		}
+		hdr, err := tar.FileInfoHeader(fi, "")
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(string(c.path), path)
+		if err != nil {
+			return err
+		}
+		hdr.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if !fi.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err == nil {
+		err = tw.Close()
+	}
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		// TempFile creates the file readable only by its owner
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), c.archive)
+	}
+	return errors.Wrapf(err, "writing OCI layout archive '%s'", c.archive)
+}
@@ -0,0 +1,271 @@
+package cache_test
+
+import (
+	"archive/tar"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/v1/layout"
+	"github.com/google/go-containerregistry/pkg/v1/random"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle"
+	"github.com/buildpacks/lifecycle/cache"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestOCILayoutCache(t *testing.T) {
+	spec.Run(t, "OCILayoutCache", testOCILayoutCache, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testOCILayoutCache(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir    string
+		layoutDir string
+		subject   *cache.OCILayoutCache
+		layerTar  string
+		diffID    string
+	)
+
+	it.Before(func() {
+		var err error
+
+		tmpDir, err = ioutil.TempDir("", "lifecycle.cache.oci_layout_cache")
+		h.AssertNil(t, err)
+
+		layoutDir = filepath.Join(tmpDir, "layout")
+		subject, err = cache.NewOCILayoutCache(layoutDir)
+		h.AssertNil(t, err)
+
+		layer, err := random.Layer(128, types.OCIUncompressedLayer)
+		h.AssertNil(t, err)
+		hash, err := layer.DiffID()
+		h.AssertNil(t, err)
+		diffID = hash.String()
+		rc, err := layer.Uncompressed()
+		h.AssertNil(t, err)
+		defer rc.Close()
+		layerTar = filepath.Join(tmpDir, "layer.tar")
+		f, err := os.Create(layerTar)
+		h.AssertNil(t, err)
+		defer f.Close()
+		_, err = io.Copy(f, rc)
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		subject.Close()
+		os.RemoveAll(tmpDir)
+	})
+
+	layerContents := func(c *cache.OCILayoutCache, diffID string) []byte {
+		t.Helper()
+		rc, err := c.RetrieveLayer(diffID)
+		h.AssertNil(t, err)
+		defer rc.Close()
+		contents, err := ioutil.ReadAll(rc)
+		h.AssertNil(t, err)
+		return contents
+	}
+
+	// commitLayer commits c holding the layer, with metadata naming it.
+	commitLayer := func(c *cache.OCILayoutCache) lifecycle.CacheMetadata {
+		t.Helper()
+		metadata := lifecycle.CacheMetadata{Buildpacks: []lifecycle.BuildpackLayersMetadata{{
+			ID:     "some.bp.id",
+			Layers: map[string]lifecycle.BuildpackLayerMetadata{"some-layer": {LayerMetadata: lifecycle.LayerMetadata{SHA: diffID}}},
+		}}}
+		h.AssertNil(t, c.AddLayerFile(layerTar, diffID))
+		h.AssertNil(t, c.SetMetadata(metadata))
+		h.AssertNil(t, c.Commit())
+		return metadata
+	}
+
+	cacheImageBlobs := func(dir string) int {
+		t.Helper()
+		fis, err := ioutil.ReadDir(filepath.Join(dir, "blobs", "sha256"))
+		h.AssertNil(t, err)
+		return len(fis)
+	}
+
+	when("#NewOCILayoutCache", func() {
+		it("creates an empty OCI layout", func() {
+			h.AssertEq(t, subject.Exists(), false)
+			h.AssertEq(t, subject.Name(), layoutDir)
+			_, err := layout.ImageIndexFromPath(layoutDir)
+			h.AssertNil(t, err)
+
+			retrieved, err := subject.RetrieveMetadata()
+			h.AssertNil(t, err)
+			h.AssertEq(t, retrieved, lifecycle.CacheMetadata{})
+		})
+
+		it("fails while another cache uses the layout", func() {
+			_, err := cache.NewOCILayoutCache(layoutDir)
+			h.AssertError(t, err, "is in use by another build")
+
+			h.AssertNil(t, subject.Close())
+			other, err := cache.NewOCILayoutCache(layoutDir)
+			h.AssertNil(t, err)
+			h.AssertNil(t, other.Close())
+		})
+	})
+
+	when("#AddLayerFile", func() {
+		it("fails if the layer doesn't match its diff ID", func() {
+			otherLayer, err := random.Layer(128, types.OCIUncompressedLayer)
+			h.AssertNil(t, err)
+			otherDiffID, err := otherLayer.DiffID()
+			h.AssertNil(t, err)
+
+			err = subject.AddLayerFile(layerTar, otherDiffID.String())
+			h.AssertError(t, err, "layer has SHA '"+diffID+"'")
+		})
+	})
+
+	when("#Commit", func() {
+		var metadata lifecycle.CacheMetadata
+
+		it.Before(func() {
+			metadata = commitLayer(subject)
+		})
+
+		it("writes the cache image to the layout under the cache ref", func() {
+			idx, err := layout.ImageIndexFromPath(layoutDir)
+			h.AssertNil(t, err)
+			manifest, err := idx.IndexManifest()
+			h.AssertNil(t, err)
+			h.AssertEq(t, len(manifest.Manifests), 1)
+			h.AssertEq(t, manifest.Manifests[0].Annotations["org.opencontainers.image.ref.name"], cache.OCILayoutCacheRef)
+
+			reopened, err := cache.NewOCILayoutCache(layoutDir)
+			h.AssertNil(t, err)
+			defer reopened.Close()
+			h.AssertEq(t, reopened.Exists(), true)
+			retrieved, err := reopened.RetrieveMetadata()
+			h.AssertNil(t, err)
+			h.AssertEq(t, retrieved, metadata)
+			h.AssertEq(t, layerContents(reopened, diffID), h.MustReadFile(t, layerTar))
+		})
+
+		it("reuses layers of the previous cache image", func() {
+			next, err := cache.NewOCILayoutCache(layoutDir)
+			h.AssertNil(t, err)
+			h.AssertNil(t, next.ReuseLayer(diffID))
+			h.AssertNil(t, next.SetMetadata(metadata))
+			h.AssertNil(t, next.Commit())
+
+			reopened, err := cache.NewOCILayoutCache(layoutDir)
+			h.AssertNil(t, err)
+			defer reopened.Close()
+			h.AssertEq(t, layerContents(reopened, diffID), h.MustReadFile(t, layerTar))
+		})
+
+		it("removes the blobs of the previous cache image that it doesn't reuse", func() {
+			// manifest, config and layer
+			h.AssertEq(t, cacheImageBlobs(layoutDir), 3)
+
+			next, err := cache.NewOCILayoutCache(layoutDir)
+			h.AssertNil(t, err)
+			h.AssertNil(t, next.SetMetadata(lifecycle.CacheMetadata{}))
+			h.AssertNil(t, next.Commit())
+			h.AssertEq(t, cacheImageBlobs(layoutDir), 2)
+
+			reopened, err := cache.NewOCILayoutCache(layoutDir)
+			h.AssertNil(t, err)
+			defer reopened.Close()
+			_, err = reopened.RetrieveLayer(diffID)
+			h.AssertError(t, err, "layer with SHA '"+diffID+"' not found")
+		})
+
+		it("fails when committed more than once", func() {
+			h.AssertError(t, subject.Commit(), "cache cannot be modified after commit")
+		})
+	})
+
+	when("#Prune", func() {
+		it("removes blobs that no manifest in the layout references", func() {
+			h.AssertNil(t, os.MkdirAll(filepath.Join(layoutDir, "blobs", "sha256"), 0777))
+			h.Mkfile(t, "stray", filepath.Join(layoutDir, "blobs", "sha256", "some-blob"))
+
+			report, err := subject.Prune()
+			h.AssertNil(t, err)
+			h.AssertEq(t, report.RemovedLayers, 1)
+			h.AssertEq(t, report.ReclaimedBytes, int64(len("stray")))
+		})
+	})
+
+	when("the path is a tar archive", func() {
+		var archivePath string
+
+		it.Before(func() {
+			archivePath = filepath.Join(tmpDir, "cache.tar")
+		})
+
+		it("commits the layout to the archive", func() {
+			archived, err := cache.NewOCILayoutCache(archivePath)
+			h.AssertNil(t, err)
+			h.AssertEq(t, archived.Name(), archivePath)
+			metadata := commitLayer(archived)
+
+			f, err := os.Open(archivePath)
+			h.AssertNil(t, err)
+			defer f.Close()
+			entries := map[string]bool{}
+			tr := tar.NewReader(f)
+			for {
+				hdr, err := tr.Next()
+				if err == io.EOF {
+					break
+				}
+				h.AssertNil(t, err)
+				entries[hdr.Name] = true
+			}
+			h.AssertEq(t, entries["oci-layout"], true)
+			h.AssertEq(t, entries["index.json"], true)
+
+			reopened, err := cache.NewOCILayoutCache(archivePath)
+			h.AssertNil(t, err)
+			defer reopened.Close()
+			retrieved, err := reopened.RetrieveMetadata()
+			h.AssertNil(t, err)
+			h.AssertEq(t, retrieved, metadata)
+			h.AssertEq(t, layerContents(reopened, diffID), h.MustReadFile(t, layerTar))
+		})
+
+		it("writes an archive that every user can read", func() {
+			h.SkipIf(t, runtime.GOOS == "windows", "file modes are not enforced on Windows")
+			archived, err := cache.NewOCILayoutCache(archivePath)
+			h.AssertNil(t, err)
+			commitLayer(archived)
+
+			fi, err := os.Stat(archivePath)
+			h.AssertNil(t, err)
+			h.AssertEq(t, fi.Mode().Perm(), os.FileMode(0644))
+		})
+
+		it("doesn't write the archive if not committed", func() {
+			archived, err := cache.NewOCILayoutCache(archivePath)
+			h.AssertNil(t, err)
+			h.AssertNil(t, archived.AddLayerFile(layerTar, diffID))
+			h.AssertNil(t, archived.Close())
+
+			h.AssertPathDoesNotExist(t, archivePath)
+		})
+
+		it("fails while another cache uses the archive", func() {
+			archived, err := cache.NewOCILayoutCache(archivePath)
+			h.AssertNil(t, err)
+			defer archived.Close()
+
+			_, err = cache.NewOCILayoutCache(archivePath)
+			h.AssertError(t, err, "cache archive '"+archivePath+"' is in use by another build")
+		})
+	})
+}
@@ -0,0 +1,369 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/buildpacks/lifecycle"
+)
+
+// DefaultGracePeriod is how long PruneSharedCache keeps an unreferenced blob after it was last added or reused.
+const DefaultGracePeriod = time.Hour
+
+// SharedCache stores layers once by digest in a blob store that many apps share.
+// Each app has only an index in the store, holding its cache metadata, which points into the blob store.
+//
+// Writers never modify a blob or an index in place: each is written to a temporary file and renamed into place,
+// so builds of any apps may use the store at once. As builds may run as different users, a build that uses a blob
+// marks it used with a file of its own in the uses directory, rather than by touching the blob it may not own.
+type SharedCache struct {
+	committed bool
+	metadata  lifecycle.CacheMetadata
+	dir       string
+	blobsDir  string
+	usesDir   string
+	tmpDir    string
+	indexPath string
+}
+
+// NewSharedCache returns the cache of the app identified by key in the shared store at dir.
+// The lifecycle doesn't chown the store, so dir must be writable by every user that builds run as;
+// the directories it creates in dir are writable by every user.
+func NewSharedCache(dir, key string) (*SharedCache, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	if key == "" {
+		return nil, errors.New("a key is required for a shared cache")
+	}
+
+	c := &SharedCache{
+		dir:      dir,
+		blobsDir: filepath.Join(dir, "blobs", "sha256"),
+		usesDir:  filepath.Join(dir, "uses"),
+		tmpDir:   filepath.Join(dir, "tmp"),
+		// keys such as image names may not be valid file names
+		indexPath: filepath.Join(dir, "index", fmt.Sprintf("%x.json", sha256.Sum256([]byte(key)))),
+	}
+	for _, d := range []string{filepath.Dir(c.blobsDir), c.blobsDir, c.usesDir, c.tmpDir, filepath.Dir(c.indexPath)} {
+		if err := mkdirShared(d); err != nil {
+			return nil, errors.Wrapf(err, "creating directory '%s'", d)
+		}
+	}
+	return c, nil
+}
+
+// mkdirShared creates the directory at path, if it doesn't exist, writable by every user regardless of umask.
+func mkdirShared(path string) error {
+	if err := os.Mkdir(path, 0777); err != nil {
+		if os.IsExist(err) {
+			return nil
+		}
+		return err
+	}
+	return os.Chmod(path, 0777)
+}
+
+func (c *SharedCache) Exists() bool {
+	if _, err := os.Stat(c.indexPath); err != nil {
+		return false
+	}
+	return true
+}
+
+func (c *SharedCache) Name() string {
+	return c.dir
+}
+
+func (c *SharedCache) SetMetadata(metadata lifecycle.CacheMetadata) error {
+	if c.committed {
+		return errCacheCommitted
+	}
+	c.metadata = metadata
+	return nil
+}
+
+func (c *SharedCache) RetrieveMetadata() (lifecycle.CacheMetadata, error) {
+	return readMetadata(c.indexPath)
+}
+
+func (c *SharedCache) AddLayerFile(tarPath string, diffID string) error {
+	if c.committed {
+		return errCacheCommitted
+	}
+	if found, err := c.touchBlob(diffID); err != nil || found {
+		// don't waste time rewriting an identical layer
+		return err
+	}
+	file, err := os.Open(tarPath)
+	if err != nil {
+		return errors.Wrapf(err, "caching layer (%s)", diffID)
+	}
+	defer file.Close()
+	return c.writeBlob(file, diffID)
+}
+
+func (c *SharedCache) AddLayer(rc io.ReadCloser, diffID string) error {
+	if c.committed {
+		return errCacheCommitted
+	}
+	if found, err := c.touchBlob(diffID); err != nil || found {
+		return err
+	}
+	return c.writeBlob(rc, diffID)
+}
+
+func (c *SharedCache) ReuseLayer(diffID string) error {
+	if c.committed {
+		return errCacheCommitted
+	}
+	found, err := c.touchBlob(diffID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return errors.Errorf("reusing layer (%s): layer not found", diffID)
+	}
+	return nil
+}
+
+func (c *SharedCache) RetrieveLayer(diffID string) (io.ReadCloser, error) {
+	path, err := c.blobPath(diffID)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, errors.Wrapf(err, "layer with SHA '%s' not found", diffID)
+		}
+		return nil, errors.Wrapf(err, "opening layer with SHA '%s'", diffID)
+	}
+	return file, nil
+}
+
+// Commit writes the index of the app. It fails if a layer the metadata references is no longer in the store,
+// which happens only if the build took longer than the grace period of a concurrent prune.
+func (c *SharedCache) Commit() error {
+	if c.committed {
+		return errCacheCommitted
+	}
+	c.committed = true
+	for _, bp := range c.metadata.Buildpacks {
+		for _, layer := range bp.Layers {
+			found, err := c.touchBlob(layer.SHA)
+			if err != nil {
+				return err
+			}
+			if !found {
+				return errors.Errorf("layer with SHA '%s' was removed from the cache before commit", layer.SHA)
+			}
+		}
+	}
+
+	tmp, err := ioutil.TempFile(c.tmpDir, "index-")
+	if err != nil {
+		return errors.Wrap(err, "creating index file")
+	}
+	defer os.Remove(tmp.Name())
+	err = json.NewEncoder(tmp).Encode(c.metadata)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrap(err, "writing index file")
+	}
+	// a prune run as another user reads every index
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return errors.Wrap(err, "writing index file")
+	}
+	if err := os.Rename(tmp.Name(), c.indexPath); err != nil {
+		return errors.Wrap(err, "committing cache")
+	}
+	return nil
+}
+
+func (c *SharedCache) blobPath(diffID string) (string, error) {
+	digest := strings.TrimPrefix(diffID, "sha256:")
+	if b, err := hex.DecodeString(digest); err != nil || len(b) != sha256.Size {
+		return "", errors.Errorf("invalid layer SHA '%s'", diffID)
+	}
+	return filepath.Join(c.blobsDir, digest), nil
+}
+
+// touchBlob reports whether the store holds the blob with diffID, and if it does, marks it as recently used
+// so that a concurrent prune keeps it.
+func (c *SharedCache) touchBlob(diffID string) (bool, error) {
+	path, err := c.blobPath(diffID)
+	if err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(path); err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, errors.Wrapf(err, "retrieving layer with SHA '%s'", diffID)
+	}
+	// the blob and the use marker may belong to other users, so replace the marker rather than touching either
+	tmp, err := ioutil.TempFile(c.tmpDir, "use-")
+	if err != nil {
+		return false, errors.Wrapf(err, "marking layer with SHA '%s' used", diffID)
+	}
+	defer os.Remove(tmp.Name())
+	if err := tmp.Close(); err != nil {
+		return false, errors.Wrapf(err, "marking layer with SHA '%s' used", diffID)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(c.usesDir, filepath.Base(path))); err != nil {
+		return false, errors.Wrapf(err, "marking layer with SHA '%s' used", diffID)
+	}
+	return true, nil
+}
+
+// writeBlob adds the layer read from r to the store, failing if its digest doesn't match diffID.
+func (c *SharedCache) writeBlob(r io.Reader, diffID string) error {
+	path, err := c.blobPath(diffID)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(c.tmpDir, "blob-")
+	if err != nil {
+		return errors.Wrapf(err, "create layer file in cache")
+	}
+	defer os.Remove(tmp.Name())
+
+	hasher := sha256.New()
+	_, err = io.Copy(io.MultiWriter(tmp, hasher), r)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return errors.Wrapf(err, "caching layer (%s)", diffID)
+	}
+	if digest := fmt.Sprintf("sha256:%x", hasher.Sum(nil)); digest != "sha256:"+strings.TrimPrefix(diffID, "sha256:") {
+		return errors.Errorf("caching layer (%s): layer has SHA '%s'", diffID, digest)
+	}
+	// temp files are private, but the builds of other apps read blobs as other users
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return errors.Wrapf(err, "caching layer (%s)", diffID)
+	}
+	// a concurrent writer of the same layer renames identical contents into place
+	return os.Rename(tmp.Name(), path)
+}
+
+// PruneSharedCache removes the blobs that no index in the shared store at dir references, once gracePeriod has
+// passed since they were last added or reused, with their use markers, and the temporary files of writers older
+// than gracePeriod.
+// The grace period protects the blobs of builds that have not committed yet.
+func PruneSharedCache(dir string, gracePeriod time.Duration) (PruneReport, error) {
+	var report PruneReport
+	refs := map[string]int{}
+	indexes, err := ioutil.ReadDir(filepath.Join(dir, "index"))
+	if err != nil && !os.IsNotExist(err) {
+		return report, err
+	}
+	for _, fi := range indexes {
+		if filepath.Ext(fi.Name()) != ".json" {
+			continue
+		}
+		indexPath := filepath.Join(dir, "index", fi.Name())
+		contents, err := ioutil.ReadFile(indexPath)
+		if err != nil {
+			return report, errors.Wrapf(err, "reading index file '%s'", indexPath)
+		}
+		// don't treat an invalid index as empty, which would remove the blobs it references
+		var metadata lifecycle.CacheMetadata
+		if err := json.Unmarshal(contents, &metadata); err != nil {
+			return report, errors.Wrapf(err, "parsing index file '%s'", indexPath)
+		}
+		for _, bp := range metadata.Buildpacks {
+			for _, layer := range bp.Layers {
+				refs[strings.TrimPrefix(layer.SHA, "sha256:")]++
+			}
+		}
+	}
+
+	expired := func(fi os.FileInfo) bool {
+		return time.Since(fi.ModTime()) > gracePeriod
+	}
+	usesDir := filepath.Join(dir, "uses")
+	// unused reports whether neither the blob nor its use marker changed within the grace period
+	unused := func(fi os.FileInfo) (bool, error) {
+		if !expired(fi) {
+			return false, nil
+		}
+		marker, err := os.Stat(filepath.Join(usesDir, fi.Name()))
+		if os.IsNotExist(err) {
+			return true, nil
+		} else if err != nil {
+			return false, errors.Wrapf(err, "checking use of layer '%s'", fi.Name())
+		}
+		return expired(marker), nil
+	}
+	blobsDir := filepath.Join(dir, "blobs", "sha256")
+	blobs, err := ioutil.ReadDir(blobsDir)
+	if err != nil && !os.IsNotExist(err) {
+		return report, err
+	}
+	for _, fi := range blobs {
+		if refs[fi.Name()] > 0 {
+			report.Layers++
+			report.Bytes += fi.Size()
+			continue
+		}
+		// a build may have reused the blob since it was listed
+		path := filepath.Join(blobsDir, fi.Name())
+		if fi, err = os.Stat(path); os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return report, errors.Wrapf(err, "checking layer '%s'", filepath.Base(path))
+		}
+		if ok, err := unused(fi); err != nil {
+			return report, err
+		} else if !ok {
+			report.Layers++
+			report.Bytes += fi.Size()
+			continue
+		}
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return report, errors.Wrapf(err, "removing layer '%s'", fi.Name())
+		}
+		report.RemovedLayers++
+		report.ReclaimedBytes += fi.Size()
+	}
+
+	markers, err := ioutil.ReadDir(usesDir)
+	if err != nil && !os.IsNotExist(err) {
+		return report, err
+	}
+	for _, fi := range markers {
+		if _, err := os.Stat(filepath.Join(blobsDir, fi.Name())); os.IsNotExist(err) && expired(fi) {
+			if err := os.Remove(filepath.Join(usesDir, fi.Name())); err != nil && !os.IsNotExist(err) {
+				return report, err
+			}
+		}
+	}
+
+	tmpFiles, err := ioutil.ReadDir(filepath.Join(dir, "tmp"))
+	if err != nil && !os.IsNotExist(err) {
+		return report, err
+	}
+	for _, fi := range tmpFiles {
+		if expired(fi) {
+			if err := os.Remove(filepath.Join(dir, "tmp", fi.Name())); err != nil && !os.IsNotExist(err) {
+				return report, err
+			}
+			report.ReclaimedBytes += fi.Size()
+		}
+	}
+	return report, nil
+}
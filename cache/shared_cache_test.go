@@ -0,0 +1,260 @@
+package cache_test
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle"
+	"github.com/buildpacks/lifecycle/cache"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestSharedCache(t *testing.T) {
+	spec.Run(t, "SharedCache", testSharedCache, spec.Parallel(), spec.Report(report.Terminal{}))
+}
+
+func testSharedCache(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir   string
+		storeDir string
+		subject  *cache.SharedCache
+		layerTar string
+		layerSHA string
+	)
+
+	it.Before(func() {
+		var err error
+
+		tmpDir, err = ioutil.TempDir("", "lifecycle.cache.shared_cache")
+		h.AssertNil(t, err)
+
+		storeDir = filepath.Join(tmpDir, "store")
+		h.AssertNil(t, os.MkdirAll(storeDir, 0777))
+
+		subject, err = cache.NewSharedCache(storeDir, "some/app:latest")
+		h.AssertNil(t, err)
+
+		layerTar = filepath.Join(tmpDir, "layer.tar")
+		h.AssertNil(t, ioutil.WriteFile(layerTar, []byte("some layer"), 0666))
+		layerSHA = fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("some layer")))
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	metadataFor := func(sha string) lifecycle.CacheMetadata {
+		return lifecycle.CacheMetadata{
+			Buildpacks: []lifecycle.BuildpackLayersMetadata{{
+				ID: "some.bp.id",
+				Layers: map[string]lifecycle.BuildpackLayerMetadata{
+					"some-layer": {LayerMetadata: lifecycle.LayerMetadata{SHA: sha}},
+				},
+			}},
+		}
+	}
+
+	blobs := func() []string {
+		t.Helper()
+		fis, err := ioutil.ReadDir(filepath.Join(storeDir, "blobs", "sha256"))
+		h.AssertNil(t, err)
+		var names []string
+		for _, fi := range fis {
+			names = append(names, fi.Name())
+		}
+		return names
+	}
+
+	// ageBlobs makes every blob, and the marker of its last use, two hours old
+	ageBlobs := func() {
+		t.Helper()
+		old := time.Now().Add(-2 * time.Hour)
+		for _, name := range blobs() {
+			h.AssertNil(t, os.Chtimes(filepath.Join(storeDir, "blobs", "sha256", name), old, old))
+			if err := os.Chtimes(filepath.Join(storeDir, "uses", name), old, old); !os.IsNotExist(err) {
+				h.AssertNil(t, err)
+			}
+		}
+	}
+
+	when("#NewSharedCache", func() {
+		it("returns an error without a key", func() {
+			_, err := cache.NewSharedCache(storeDir, "")
+			h.AssertError(t, err, "a key is required for a shared cache")
+		})
+
+		it("creates directories that builds as every user can write to", func() {
+			h.SkipIf(t, runtime.GOOS == "windows", "file modes are not supported on windows")
+			for _, dir := range []string{"blobs", filepath.Join("blobs", "sha256"), "uses", "tmp", "index"} {
+				fi, err := os.Stat(filepath.Join(storeDir, dir))
+				h.AssertNil(t, err)
+				h.AssertEq(t, fi.Mode().Perm(), os.FileMode(0777))
+			}
+		})
+	})
+
+	when("#AddLayerFile", func() {
+		it("stores the layer by digest once for every app", func() {
+			h.AssertNil(t, subject.AddLayerFile(layerTar, layerSHA))
+			h.AssertNil(t, subject.SetMetadata(metadataFor(layerSHA)))
+			h.AssertNil(t, subject.Commit())
+
+			other, err := cache.NewSharedCache(storeDir, "other/app")
+			h.AssertNil(t, err)
+			h.AssertEq(t, other.Exists(), false)
+			h.AssertNil(t, other.AddLayerFile(layerTar, layerSHA))
+			h.AssertNil(t, other.SetMetadata(metadataFor(layerSHA)))
+			h.AssertNil(t, other.Commit())
+
+			h.AssertEq(t, blobs(), []string{strings.TrimPrefix(layerSHA, "sha256:")})
+			rc, err := other.RetrieveLayer(layerSHA)
+			h.AssertNil(t, err)
+			defer rc.Close()
+			contents, err := ioutil.ReadAll(rc)
+			h.AssertNil(t, err)
+			h.AssertEq(t, string(contents), "some layer")
+		})
+
+		it("makes the layer readable by the builds of other apps", func() {
+			h.SkipIf(t, runtime.GOOS == "windows", "file modes are not supported on windows")
+			h.AssertNil(t, subject.AddLayerFile(layerTar, layerSHA))
+
+			fi, err := os.Stat(filepath.Join(storeDir, "blobs", "sha256", strings.TrimPrefix(layerSHA, "sha256:")))
+			h.AssertNil(t, err)
+			h.AssertEq(t, fi.Mode().Perm(), os.FileMode(0644))
+		})
+
+		it("fails if the layer doesn't match its digest", func() {
+			otherSHA := fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("other layer")))
+
+			err := subject.AddLayerFile(layerTar, otherSHA)
+			h.AssertError(t, err, fmt.Sprintf("layer has SHA '%s'", layerSHA))
+			h.AssertEq(t, len(blobs()), 0)
+		})
+	})
+
+	when("#ReuseLayer", func() {
+		it("fails if the store doesn't hold the layer", func() {
+			h.AssertError(t, subject.ReuseLayer(layerSHA), "layer not found")
+		})
+
+		it("marks a blob it can't modify as used", func() {
+			h.SkipIf(t, runtime.GOOS == "windows", "file modes are not supported on windows")
+			h.AssertNil(t, subject.AddLayerFile(layerTar, layerSHA))
+			ageBlobs()
+			blobPath := filepath.Join(storeDir, "blobs", "sha256", strings.TrimPrefix(layerSHA, "sha256:"))
+			// like a blob written by a build as another user
+			h.AssertNil(t, os.Chmod(blobPath, 0444))
+			before, err := os.Stat(blobPath)
+			h.AssertNil(t, err)
+
+			h.AssertNil(t, subject.ReuseLayer(layerSHA))
+			h.AssertNil(t, subject.SetMetadata(lifecycle.CacheMetadata{}))
+			h.AssertNil(t, subject.Commit())
+
+			after, err := os.Stat(blobPath)
+			h.AssertNil(t, err)
+			h.AssertEq(t, after.ModTime(), before.ModTime())
+			report, err := cache.PruneSharedCache(storeDir, time.Hour)
+			h.AssertNil(t, err)
+			h.AssertEq(t, report.RemovedLayers, 0)
+			h.AssertEq(t, blobs(), []string{strings.TrimPrefix(layerSHA, "sha256:")})
+		})
+	})
+
+	when("#Commit", func() {
+		it("writes the metadata of the app only", func() {
+			h.AssertNil(t, subject.AddLayerFile(layerTar, layerSHA))
+			h.AssertNil(t, subject.SetMetadata(metadataFor(layerSHA)))
+
+			retrieved, err := subject.RetrieveMetadata()
+			h.AssertNil(t, err)
+			h.AssertEq(t, retrieved, lifecycle.CacheMetadata{})
+
+			h.AssertNil(t, subject.Commit())
+
+			h.AssertEq(t, subject.Exists(), true)
+			retrieved, err = subject.RetrieveMetadata()
+			h.AssertNil(t, err)
+			h.AssertEq(t, retrieved, metadataFor(layerSHA))
+
+			other, err := cache.NewSharedCache(storeDir, "other/app")
+			h.AssertNil(t, err)
+			retrieved, err = other.RetrieveMetadata()
+			h.AssertNil(t, err)
+			h.AssertEq(t, retrieved, lifecycle.CacheMetadata{})
+		})
+
+		it("fails if a referenced layer was pruned", func() {
+			h.AssertNil(t, subject.AddLayerFile(layerTar, layerSHA))
+			h.AssertNil(t, subject.SetMetadata(metadataFor(layerSHA)))
+			ageBlobs()
+			_, err := cache.PruneSharedCache(storeDir, time.Hour)
+			h.AssertNil(t, err)
+
+			h.AssertError(t, subject.Commit(), "was removed from the cache before commit")
+		})
+
+		it("fails when committed more than once", func() {
+			h.AssertNil(t, subject.Commit())
+			h.AssertError(t, subject.Commit(), "cache cannot be modified after commit")
+		})
+	})
+
+	when("#PruneSharedCache", func() {
+		var unreferencedSHA string
+
+		it.Before(func() {
+			h.AssertNil(t, subject.AddLayerFile(layerTar, layerSHA))
+			h.AssertNil(t, subject.SetMetadata(metadataFor(layerSHA)))
+			h.AssertNil(t, subject.Commit())
+
+			other, err := cache.NewSharedCache(storeDir, "other/app")
+			h.AssertNil(t, err)
+			unreferencedTar := filepath.Join(tmpDir, "unreferenced.tar")
+			h.AssertNil(t, ioutil.WriteFile(unreferencedTar, []byte("unreferenced layer"), 0666))
+			unreferencedSHA = fmt.Sprintf("sha256:%x", sha256.Sum256([]byte("unreferenced layer")))
+			h.AssertNil(t, other.AddLayerFile(unreferencedTar, unreferencedSHA))
+		})
+
+		it("removes unreferenced layers older than the grace period", func() {
+			ageBlobs()
+
+			report, err := cache.PruneSharedCache(storeDir, time.Hour)
+			h.AssertNil(t, err)
+			h.AssertEq(t, report, cache.PruneReport{
+				Layers:         1,
+				Bytes:          int64(len("some layer")),
+				RemovedLayers:  1,
+				ReclaimedBytes: int64(len("unreferenced layer")),
+			})
+			h.AssertEq(t, blobs(), []string{strings.TrimPrefix(layerSHA, "sha256:")})
+		})
+
+		it("keeps unreferenced layers within the grace period", func() {
+			report, err := cache.PruneSharedCache(storeDir, time.Hour)
+			h.AssertNil(t, err)
+			h.AssertEq(t, report.Layers, 2)
+			h.AssertEq(t, report.RemovedLayers, 0)
+		})
+
+		it("fails without removing layers if an index is invalid", func() {
+			ageBlobs()
+			h.AssertNil(t, ioutil.WriteFile(filepath.Join(storeDir, "index", "some-index.json"), []byte("garbage"), 0666))
+
+			_, err := cache.PruneSharedCache(storeDir, time.Hour)
+			h.AssertNotNil(t, err)
+			h.AssertEq(t, len(blobs()), 2)
+		})
+	})
+}
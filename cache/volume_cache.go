@@ -3,10 +3,13 @@ package cache
 import (
 	"encoding/json"
 	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 
@@ -14,14 +17,33 @@ import (
 )
 
 type VolumeCache struct {
+	// MaxSize is the maximum total size in bytes of the layers kept at Commit, or 0 for no limit.
+	MaxSize int64
+	// MaxAge is the maximum time since a layer was last used by a previous commit for it to be kept at Commit,
+	// or 0 for no limit. Layers that no previous commit holds are new and never expire.
+	MaxAge time.Duration
+
 	committed    bool
+	lock         *os.File
 	dir          string
 	backupDir    string
 	stagingDir   string
 	committedDir string
+	// lastUsed holds the times that layer files were last used by a previous commit, before this build used them.
+	lastUsed map[string]time.Time
 }
 
+// lockPollInterval is how often NewVolumeCacheWithTimeout retries the lock of a cache directory in use.
+const lockPollInterval = 100 * time.Millisecond
+
+// NewVolumeCache returns the cache in dir, failing if another build is using it.
 func NewVolumeCache(dir string) (*VolumeCache, error) {
+	return NewVolumeCacheWithTimeout(dir, 0)
+}
+
+// NewVolumeCacheWithTimeout returns the cache in dir, waiting up to lockTimeout for another build to stop using it.
+// The cache holds an advisory lock on dir until it is committed or closed.
+func NewVolumeCacheWithTimeout(dir string, lockTimeout time.Duration) (*VolumeCache, error) {
 	if _, err := os.Stat(dir); err != nil {
 		return nil, err
 	}
@@ -31,21 +53,87 @@ func NewVolumeCache(dir string) (*VolumeCache, error) {
 		backupDir:    filepath.Join(dir, "committed-backup"),
 		stagingDir:   filepath.Join(dir, "staging"),
 		committedDir: filepath.Join(dir, "committed"),
+		lastUsed:     map[string]time.Time{},
 	}
 
-	if err := c.setupStagingDir(); err != nil {
-		return nil, errors.Wrapf(err, "initializing staging directory '%s'", c.stagingDir)
+	var err error
+	if c.lock, err = lockDir(dir, lockTimeout); err != nil {
+		return nil, err
+	}
+	if err := c.init(); err != nil {
+		c.lock.Close()
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *VolumeCache) init() error {
+	if err := c.recoverCommit(); err != nil {
+		return errors.Wrapf(err, "recovering backup directory '%s'", c.backupDir)
 	}
 
-	if err := os.RemoveAll(c.backupDir); err != nil {
-		return nil, errors.Wrapf(err, "removing backup directory '%s'", c.backupDir)
+	if err := c.setupStagingDir(); err != nil {
+		return errors.Wrapf(err, "initializing staging directory '%s'", c.stagingDir)
 	}
 
 	if err := os.MkdirAll(c.committedDir, 0777); err != nil {
-		return nil, errors.Wrapf(err, "creating committed directory '%s'", c.committedDir)
+		return errors.Wrapf(err, "creating committed directory '%s'", c.committedDir)
 	}
+	return nil
+}
 
-	return c, nil
+// recoverCommit finishes the cleanup of a Commit that was interrupted. If the committed layers were backed up
+// but the staged layers not yet moved into place, the backup is restored, so the previous layers are kept.
+func (c *VolumeCache) recoverCommit() error {
+	if _, err := os.Stat(c.backupDir); os.IsNotExist(err) {
+		return nil
+	}
+	if _, err := os.Stat(c.committedDir); os.IsNotExist(err) {
+		return os.Rename(c.backupDir, c.committedDir)
+	}
+	return os.RemoveAll(c.backupDir)
+}
+
+// lockDir takes the lock of the cache in dir, retrying until timeout has passed if another build holds it.
+func lockDir(dir string, timeout time.Duration) (*os.File, error) {
+	return lockFile(filepath.Join(dir, "lock"), "cache directory '"+dir+"'", timeout)
+}
+
+// lockFile takes the lock file at path of the cache described by name, retrying until timeout has passed
+// if another build holds it.
+func lockFile(path, name string, timeout time.Duration) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0666)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening lock file '%s'", path)
+	}
+	deadline := time.Now().Add(timeout)
+	for {
+		locked, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, errors.Wrapf(err, "locking '%s'", path)
+		}
+		if locked {
+			return f, nil
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			if timeout > 0 {
+				return nil, errors.Errorf("timed out after %s waiting for another build to release %s", timeout, name)
+			}
+			return nil, errors.Errorf("%s is in use by another build", name)
+		}
+		time.Sleep(lockPollInterval)
+	}
+}
+
+// Close releases the lock on the cache directory without committing. The cache can't be modified after Close.
+func (c *VolumeCache) Close() error {
+	if c.committed {
+		return nil
+	}
+	c.committed = true
+	return c.lock.Close()
 }
 
 func (c *VolumeCache) Exists() bool {
@@ -63,7 +151,10 @@ func (c *VolumeCache) SetMetadata(metadata lifecycle.CacheMetadata) error {
 	if c.committed {
 		return errCacheCommitted
 	}
-	metadataPath := filepath.Join(c.stagingDir, MetadataLabel)
+	return writeMetadata(filepath.Join(c.stagingDir, MetadataLabel), metadata)
+}
+
+func writeMetadata(metadataPath string, metadata lifecycle.CacheMetadata) error {
 	file, err := os.Create(metadataPath)
 	if err != nil {
 		return errors.Wrapf(err, "creating metadata file '%s'", metadataPath)
@@ -78,7 +169,10 @@ func (c *VolumeCache) SetMetadata(metadata lifecycle.CacheMetadata) error {
 }
 
 func (c *VolumeCache) RetrieveMetadata() (lifecycle.CacheMetadata, error) {
-	metadataPath := filepath.Join(c.committedDir, MetadataLabel)
+	return readMetadata(filepath.Join(c.committedDir, MetadataLabel))
+}
+
+func readMetadata(metadataPath string) (lifecycle.CacheMetadata, error) {
 	file, err := os.Open(metadataPath)
 	if err != nil {
 		if os.IsNotExist(err) {
@@ -99,10 +193,11 @@ func (c *VolumeCache) AddLayerFile(tarPath string, diffID string) error {
 	if c.committed {
 		return errCacheCommitted
 	}
+	c.recordLastUse(diffID)
 	layerTar := diffIDPath(c.stagingDir, diffID)
 	if _, err := os.Stat(layerTar); err == nil {
 		// don't waste time rewriting an identical layer
-		return nil
+		return touchLayer(layerTar, diffID)
 	}
 
 	if err := copyFile(tarPath, layerTar); err != nil {
@@ -115,6 +210,7 @@ func (c *VolumeCache) AddLayer(rc io.ReadCloser, diffID string) error {
 	if c.committed {
 		return errCacheCommitted
 	}
+	c.recordLastUse(diffID)
 
 	fh, err := os.Create(diffIDPath(c.stagingDir, diffID))
 	if err != nil {
@@ -132,9 +228,32 @@ func (c *VolumeCache) ReuseLayer(diffID string) error {
 	if c.committed {
 		return errCacheCommitted
 	}
-	if err := os.Link(diffIDPath(c.committedDir, diffID), diffIDPath(c.stagingDir, diffID)); err != nil && !os.IsExist(err) {
+	c.recordLastUse(diffID)
+	layerTar := diffIDPath(c.stagingDir, diffID)
+	if err := os.Link(diffIDPath(c.committedDir, diffID), layerTar); err != nil && !os.IsExist(err) {
 		return errors.Wrapf(err, "reusing layer (%s)", diffID)
 	}
+	return touchLayer(layerTar, diffID)
+}
+
+// recordLastUse remembers when the previous commit that holds the layer used it, before this build marks the
+// layer used. The reused layer is a hard link to the committed one, so touching it changes both.
+func (c *VolumeCache) recordLastUse(diffID string) {
+	name := filepath.Base(diffIDPath("", diffID))
+	if _, ok := c.lastUsed[name]; ok {
+		return
+	}
+	if fi, err := os.Stat(filepath.Join(c.committedDir, name)); err == nil {
+		c.lastUsed[name] = fi.ModTime()
+	}
+}
+
+// touchLayer marks the layer at path as used now, which the next build reads as the last use of the layer.
+func touchLayer(path, diffID string) error {
+	now := time.Now()
+	if err := os.Chtimes(path, now, now); err != nil {
+		return errors.Wrapf(err, "updating modification time of layer (%s)", diffID)
+	}
 	return nil
 }
 
@@ -176,6 +295,10 @@ func (c *VolumeCache) Commit() error {
 		return errCacheCommitted
 	}
 	c.committed = true
+	defer c.lock.Close()
+	if err := c.evict(); err != nil {
+		return errors.Wrap(err, "evicting layers")
+	}
 	if err := os.Rename(c.committedDir, c.backupDir); err != nil {
 		return errors.Wrap(err, "backing up cache")
 	}
@@ -191,6 +314,128 @@ func (c *VolumeCache) Commit() error {
 	return nil
 }
 
+// evict removes the staged layers that a previous commit last used longer than MaxAge ago, then the least
+// recently used layers until the rest fit in MaxSize, and drops the removed layers from the staged metadata.
+// Layers are used when they are cached or reused; as every staged layer is used by this build, a layer that a
+// previous commit holds counts as used when that commit last used it.
+func (c *VolumeCache) evict() error {
+	if c.MaxSize <= 0 && c.MaxAge <= 0 {
+		return nil
+	}
+	layers, total, err := layerFiles(c.stagingDir)
+	if err != nil {
+		return err
+	}
+	lastUsed := func(fi os.FileInfo) time.Time {
+		if t, ok := c.lastUsed[fi.Name()]; ok {
+			return t
+		}
+		return fi.ModTime()
+	}
+	sort.SliceStable(layers, func(i, j int) bool {
+		return lastUsed(layers[i]).Before(lastUsed(layers[j]))
+	})
+
+	evicted := map[string]bool{}
+	for _, fi := range layers {
+		expired := c.MaxAge > 0 && time.Since(lastUsed(fi)) > c.MaxAge
+		if !expired && (c.MaxSize <= 0 || total <= c.MaxSize) {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.stagingDir, fi.Name())); err != nil {
+			return err
+		}
+		total -= fi.Size()
+		evicted[fi.Name()] = true
+	}
+	if len(evicted) == 0 {
+		return nil
+	}
+
+	metadataPath := filepath.Join(c.stagingDir, MetadataLabel)
+	if _, err := os.Stat(metadataPath); os.IsNotExist(err) {
+		return nil
+	}
+	metadata, err := readMetadata(metadataPath)
+	if err != nil {
+		return err
+	}
+	for _, bp := range metadata.Buildpacks {
+		for name, layer := range bp.Layers {
+			if evicted[filepath.Base(diffIDPath("", layer.SHA))] {
+				delete(bp.Layers, name)
+			}
+		}
+	}
+	return writeMetadata(metadataPath, metadata)
+}
+
+// PruneReport describes the layers removed from a cache directory by Prune, and the layers that remain.
+type PruneReport struct {
+	Layers         int
+	Bytes          int64
+	RemovedLayers  int
+	ReclaimedBytes int64
+}
+
+// Prune removes the committed layers that the committed metadata doesn't reference.
+// Referenced layers are never removed.
+func (c *VolumeCache) Prune() (PruneReport, error) {
+	var report PruneReport
+	// unlike RetrieveMetadata, don't treat invalid metadata as empty, which would remove every layer
+	var metadata lifecycle.CacheMetadata
+	metadataPath := filepath.Join(c.committedDir, MetadataLabel)
+	if contents, err := ioutil.ReadFile(metadataPath); err == nil {
+		if err := json.Unmarshal(contents, &metadata); err != nil {
+			return report, errors.Wrapf(err, "parsing metadata file '%s'", metadataPath)
+		}
+	} else if !os.IsNotExist(err) {
+		return report, errors.Wrapf(err, "reading metadata file '%s'", metadataPath)
+	}
+	referenced := map[string]bool{}
+	for _, bp := range metadata.Buildpacks {
+		for _, layer := range bp.Layers {
+			referenced[filepath.Base(diffIDPath("", layer.SHA))] = true
+		}
+	}
+	layers, _, err := layerFiles(c.committedDir)
+	if err != nil {
+		return report, err
+	}
+	for _, fi := range layers {
+		if referenced[fi.Name()] {
+			report.Layers++
+			report.Bytes += fi.Size()
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.committedDir, fi.Name())); err != nil {
+			return report, errors.Wrapf(err, "removing layer '%s'", fi.Name())
+		}
+		report.RemovedLayers++
+		report.ReclaimedBytes += fi.Size()
+	}
+	return report, nil
+}
+
+// layerFiles returns the layer tarballs in dir and their total size.
+func layerFiles(dir string) ([]os.FileInfo, int64, error) {
+	fis, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, 0, err
+	}
+	var (
+		layers []os.FileInfo
+		total  int64
+	)
+	for _, fi := range fis {
+		if fi.Mode().IsRegular() && filepath.Ext(fi.Name()) == ".tar" {
+			layers = append(layers, fi)
+			total += fi.Size()
+		}
+	}
+	return layers, total, nil
+}
+
 func diffIDPath(basePath, diffID string) string {
 	if runtime.GOOS == "windows" {
 		// Avoid colons in Windows file paths
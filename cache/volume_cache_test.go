@@ -121,6 +121,73 @@ func testVolumeCache(t *testing.T, when spec.G, it spec.S) {
 				}
 			})
 		})
+
+		when("the committed dir was backed up by an interrupted commit", func() {
+			it.Before(func() {
+				h.AssertNil(t, os.MkdirAll(backupDir, 0777))
+				h.AssertNil(t, ioutil.WriteFile(filepath.Join(backupDir, "some-layer.tar"), []byte("some data"), 0666))
+			})
+
+			it("restores the backup if the committed dir is missing", func() {
+				subject, err := cache.NewVolumeCache(volumeDir)
+				h.AssertNil(t, err)
+
+				hasLayer, err := subject.HasLayer("some-layer")
+				h.AssertNil(t, err)
+				h.AssertEq(t, hasLayer, true)
+				h.AssertPathDoesNotExist(t, backupDir)
+			})
+
+			it("keeps the committed dir if it exists", func() {
+				h.AssertNil(t, os.MkdirAll(committedDir, 0777))
+				h.AssertNil(t, ioutil.WriteFile(filepath.Join(committedDir, "other-layer.tar"), []byte("other data"), 0666))
+
+				subject, err := cache.NewVolumeCache(volumeDir)
+				h.AssertNil(t, err)
+
+				hasLayer, err := subject.HasLayer("other-layer")
+				h.AssertNil(t, err)
+				h.AssertEq(t, hasLayer, true)
+				h.AssertPathDoesNotExist(t, backupDir)
+			})
+		})
+
+		when("another cache uses the volume", func() {
+			var other *cache.VolumeCache
+
+			it.Before(func() {
+				var err error
+				other, err = cache.NewVolumeCache(volumeDir)
+				h.AssertNil(t, err)
+			})
+
+			it("returns an error", func() {
+				_, err := cache.NewVolumeCache(volumeDir)
+				h.AssertError(t, err, "is in use by another build")
+			})
+
+			it("returns an error if the other cache doesn't commit within the timeout", func() {
+				_, err := cache.NewVolumeCacheWithTimeout(volumeDir, 200*time.Millisecond)
+				h.AssertError(t, err, "timed out after 200ms waiting for another build to release cache directory")
+			})
+
+			it("waits for the other cache to commit", func() {
+				go func() {
+					time.Sleep(200 * time.Millisecond)
+					other.Commit()
+				}()
+
+				_, err := cache.NewVolumeCacheWithTimeout(volumeDir, 10*time.Second)
+				h.AssertNil(t, err)
+			})
+
+			it("succeeds once the other cache is closed", func() {
+				h.AssertNil(t, other.Close())
+
+				_, err := cache.NewVolumeCache(volumeDir)
+				h.AssertNil(t, err)
+			})
+		})
 	})
 
 	when("VolumeCache", func() {
@@ -514,6 +581,125 @@ func testVolumeCache(t *testing.T, when spec.G, it spec.S) {
 				})
 			})
 
+			when("limits are set", func() {
+				var metadata lifecycle.CacheMetadata
+
+				it.Before(func() {
+					for sha, age := range map[string]time.Duration{"older_sha": 2 * time.Hour, "old_sha": time.Hour, "new_sha": 0} {
+						path := filepath.Join(stagingDir, sha+".tar")
+						h.AssertNil(t, ioutil.WriteFile(path, []byte("some data"), 0666))
+						modTime := time.Now().Add(-age)
+						h.AssertNil(t, os.Chtimes(path, modTime, modTime))
+					}
+					metadata = lifecycle.CacheMetadata{
+						Buildpacks: []lifecycle.BuildpackLayersMetadata{{
+							ID: "some.bp.id",
+							Layers: map[string]lifecycle.BuildpackLayerMetadata{
+								"old-layer":   {LayerMetadata: lifecycle.LayerMetadata{SHA: "old_sha"}},
+								"older-layer": {LayerMetadata: lifecycle.LayerMetadata{SHA: "older_sha"}},
+								"new-layer":   {LayerMetadata: lifecycle.LayerMetadata{SHA: "new_sha"}},
+							},
+						}},
+					}
+					h.AssertNil(t, subject.SetMetadata(metadata))
+				})
+
+				assertEvicted := func(evicted ...string) {
+					t.Helper()
+					retrievedMetadata, err := subject.RetrieveMetadata()
+					h.AssertNil(t, err)
+					for name, sha := range map[string]string{"old-layer": "old_sha", "older-layer": "older_sha", "new-layer": "new_sha"} {
+						_, inMetadata := retrievedMetadata.Buildpacks[0].Layers[name]
+						hasLayer, err := subject.HasLayer(sha)
+						h.AssertNil(t, err)
+						shouldEvict := false
+						for _, e := range evicted {
+							shouldEvict = shouldEvict || e == name
+						}
+						h.AssertEq(t, inMetadata, !shouldEvict)
+						h.AssertEq(t, hasLayer, !shouldEvict)
+					}
+				}
+
+				it("evicts the least recently cached layers to fit in the max size", func() {
+					subject.MaxSize = int64(len("some data"))
+
+					h.AssertNil(t, subject.Commit())
+					assertEvicted("older-layer", "old-layer")
+				})
+
+				it("evicts layers cached longer ago than the max age", func() {
+					subject.MaxAge = 30 * time.Minute
+
+					h.AssertNil(t, subject.Commit())
+					assertEvicted("old-layer", "older-layer")
+				})
+
+				it("keeps layers within the limits", func() {
+					subject.MaxSize = int64(3 * len("some data"))
+					subject.MaxAge = 3 * time.Hour
+
+					h.AssertNil(t, subject.Commit())
+					assertEvicted()
+				})
+			})
+
+			when("a max age is set across builds", func() {
+				var layerTar string
+
+				it.Before(func() {
+					layerTar = filepath.Join(tmpDir, "layer.tar")
+					h.AssertNil(t, ioutil.WriteFile(layerTar, []byte("some data"), 0666))
+				})
+
+				// build commits a build that caches the given layers, reusing them if the previous commit holds them.
+				build := func(maxAge time.Duration, shas ...string) *cache.VolumeCache {
+					t.Helper()
+					c, err := cache.NewVolumeCache(volumeDir)
+					h.AssertNil(t, err)
+					c.MaxAge = maxAge
+					layers := map[string]lifecycle.BuildpackLayerMetadata{}
+					for _, sha := range shas {
+						if hasLayer, err := c.HasLayer(sha); err == nil && hasLayer {
+							h.AssertNil(t, c.ReuseLayer(sha))
+						} else {
+							h.AssertNil(t, c.AddLayerFile(layerTar, sha))
+						}
+						layers[sha] = lifecycle.BuildpackLayerMetadata{LayerMetadata: lifecycle.LayerMetadata{SHA: sha}}
+					}
+					h.AssertNil(t, c.SetMetadata(lifecycle.CacheMetadata{
+						Buildpacks: []lifecycle.BuildpackLayersMetadata{{ID: "some.bp.id", Layers: layers}},
+					}))
+					h.AssertNil(t, c.Commit())
+					return c
+				}
+
+				it("evicts reused layers that the previous commit last used longer ago than the max age", func() {
+					h.AssertNil(t, subject.Close())
+					build(0, "old_sha")
+					time.Sleep(200 * time.Millisecond)
+
+					c := build(100*time.Millisecond, "old_sha", "new_sha")
+					for sha, kept := range map[string]bool{"old_sha": false, "new_sha": true} {
+						hasLayer, err := c.HasLayer(sha)
+						h.AssertNil(t, err)
+						h.AssertEq(t, hasLayer, kept)
+					}
+				})
+
+				it("keeps layers that every build reuses", func() {
+					h.AssertNil(t, subject.Close())
+					build(0, "some_sha")
+					time.Sleep(200 * time.Millisecond)
+					build(time.Hour, "some_sha")
+
+					c := build(100*time.Millisecond, "some_sha")
+					hasLayer, err := c.HasLayer("some_sha")
+					h.AssertNil(t, err)
+					h.AssertEq(t, hasLayer, true)
+				})
+			})
+
 			when("attempting to commit more than once", func() {
 				it("should fail", func() {
 					err := subject.Commit()
@@ -524,5 +710,74 @@ func testVolumeCache(t *testing.T, when spec.G, it spec.S) {
 				})
 			})
 		})
+
+		when("#Prune", func() {
+			it.Before(func() {
+				h.AssertNil(t, ioutil.WriteFile(filepath.Join(committedDir, "referenced_sha.tar"), []byte("referenced data"), 0666))
+				h.AssertNil(t, ioutil.WriteFile(filepath.Join(committedDir, "unreferenced_sha.tar"), []byte("unreferenced data"), 0666))
+				h.AssertNil(t, ioutil.WriteFile(
+					filepath.Join(committedDir, "io.buildpacks.lifecycle.cache.metadata"),
+					[]byte(`{"buildpacks": [{"key": "some.bp.id", "layers": {"some-layer": {"sha": "referenced_sha"}}}]}`),
+					0666,
+				))
+			})
+
+			it("removes only the layers the metadata doesn't reference", func() {
+				report, err := subject.Prune()
+				h.AssertNil(t, err)
+				h.AssertEq(t, report, cache.PruneReport{
+					Layers:         1,
+					Bytes:          int64(len("referenced data")),
+					RemovedLayers:  1,
+					ReclaimedBytes: int64(len("unreferenced data")),
+				})
+
+				hasLayer, err := subject.HasLayer("referenced_sha")
+				h.AssertNil(t, err)
+				h.AssertEq(t, hasLayer, true)
+				hasLayer, err = subject.HasLayer("unreferenced_sha")
+				h.AssertNil(t, err)
+				h.AssertEq(t, hasLayer, false)
+			})
+
+			it("never removes referenced layers", func() {
+				path := filepath.Join(committedDir, "old_sha.tar")
+				h.AssertNil(t, ioutil.WriteFile(path, []byte("old data"), 0666))
+				modTime := time.Now().Add(-2 * time.Hour)
+				h.AssertNil(t, os.Chtimes(path, modTime, modTime))
+				h.AssertNil(t, ioutil.WriteFile(
+					filepath.Join(committedDir, "io.buildpacks.lifecycle.cache.metadata"),
+					[]byte(`{"buildpacks": [{"key": "some.bp.id", "layers": {"some-layer": {"sha": "referenced_sha"}, "old-layer": {"sha": "old_sha"}}}]}`),
+					0666,
+				))
+				subject.MaxAge = time.Hour
+
+				report, err := subject.Prune()
+				h.AssertNil(t, err)
+				h.AssertEq(t, report, cache.PruneReport{
+					Layers:         2,
+					Bytes:          int64(len("referenced data") + len("old data")),
+					RemovedLayers:  1,
+					ReclaimedBytes: int64(len("unreferenced data")),
+				})
+
+				hasLayer, err := subject.HasLayer("old_sha")
+				h.AssertNil(t, err)
+				h.AssertEq(t, hasLayer, true)
+				metadata, err := subject.RetrieveMetadata()
+				h.AssertNil(t, err)
+				h.AssertEq(t, len(metadata.Buildpacks[0].Layers), 2)
+			})
+
+			it("fails without removing layers if the metadata is invalid", func() {
+				h.AssertNil(t, ioutil.WriteFile(filepath.Join(committedDir, "io.buildpacks.lifecycle.cache.metadata"), []byte("garbage"), 0666))
+
+				_, err := subject.Prune()
+				h.AssertNotNil(t, err)
+				hasLayer, err := subject.HasLayer("referenced_sha")
+				h.AssertNil(t, err)
+				h.AssertEq(t, hasLayer, true)
+			})
+		})
 	})
 }
@@ -149,6 +149,8 @@ func testCache(t *testing.T, when spec.G, it spec.S) {
 
 				when("the SHAs match", func() {
 					it.Before(func() {
+						// release the lock held by the cache opened for every test
+						h.AssertNil(t, testCache.(*cache.VolumeCache).Close())
 						previousCache, err := cache.NewVolumeCache(cacheDir)
 						h.AssertNil(t, err)
 
@@ -24,6 +24,9 @@ const (
 	// CodeFailedDetectWithErrors indicated that no buildpacks detected and at least one errored
 	CodeFailedDetectWithErrors = 101
 	CodeDetectError            = 102 // CodeDetectError indicates generic detect error
+	// CodeFailedDetectWithTimeout indicates that no buildpacks detected and at least one timed out
+	CodeFailedDetectWithTimeout = 103
+	CodeInvalidBuildpacks       = 104 // CodeInvalidBuildpacks indicates that buildpacks failed validation
 
 	// analyze phase errors: 200-299
 	CodeAnalyzeError = 202 // CodeAnalyzeError indicates generic analyze error
@@ -6,6 +6,8 @@ import (
 	"os"
 	"path/filepath"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/buildpacks/lifecycle/api"
 )
@@ -42,14 +44,26 @@ const (
 	EnvBuildpacksDir       = "CNB_BUILDPACKS_DIR"
 	EnvCacheDir            = "CNB_CACHE_DIR"
 	EnvCacheImage          = "CNB_CACHE_IMAGE"
+	EnvCacheKey            = "CNB_CACHE_KEY"
+	EnvCacheLockTimeout    = "CNB_CACHE_LOCK_TIMEOUT" // defaults to 0 (fail if in use)
+	EnvCacheMaxAge         = "CNB_CACHE_MAX_AGE"      // defaults to 0 (no limit)
+	EnvCacheMaxSize        = "CNB_CACHE_MAX_SIZE"     // defaults to 0 (no limit)
 	EnvDeprecationMode     = "CNB_DEPRECATION_MODE"
+	EnvDetectCacheDir      = "CNB_DETECT_CACHE_DIR"
+	EnvDetectConcurrency   = "CNB_DETECT_CONCURRENCY" // defaults to 0 (unbounded)
+	EnvDetectReportPath    = "CNB_DETECT_REPORT_PATH"
+	EnvDetectTimeout       = "CNB_DETECT_TIMEOUT" // defaults to 0 (no timeout)
+	EnvDryRun              = "CNB_DRY_RUN"        // defaults to false
+	EnvEnvPolicyPath       = "CNB_ENV_POLICY_PATH"
 	EnvGID                 = "CNB_GROUP_ID"
+	EnvGroupHint           = "CNB_GROUP_HINT"
 	EnvGroupPath           = "CNB_GROUP_PATH"
 	EnvLaunchCacheDir      = "CNB_LAUNCH_CACHE_DIR"
 	EnvLayersDir           = "CNB_LAYERS_DIR"
 	EnvLogLevel            = "CNB_LOG_LEVEL"
 	EnvNoColor             = "CNB_NO_COLOR" // defaults to false
 	EnvOrderPath           = "CNB_ORDER_PATH"
+	EnvOutputFormat        = "CNB_OUTPUT_FORMAT"
 	EnvPlanPath            = "CNB_PLAN_PATH"
 	EnvPlatformAPI         = "CNB_PLATFORM_API"
 	EnvPlatformDir         = "CNB_PLATFORM_DIR"
@@ -57,12 +71,19 @@ const (
 	EnvProcessType         = "CNB_PROCESS_TYPE"
 	EnvProjectMetadataPath = "CNB_PROJECT_METADATA_PATH"
 	EnvReportPath          = "CNB_REPORT_PATH"
+	EnvResume              = "CNB_BUILD_RESUME" // defaults to false
 	EnvRunImage            = "CNB_RUN_IMAGE"
+	EnvSBOMDir             = "CNB_SBOM_DIR"
+	EnvSBOMLayer           = "CNB_SBOM_LAYER"          // defaults to false
+	EnvSkipDetectCache     = "CNB_SKIP_DETECT_CACHE"   // defaults to false
 	EnvSkipLayers          = "CNB_ANALYZE_SKIP_LAYERS" // defaults to false
 	EnvSkipRestore         = "CNB_SKIP_RESTORE"        // defaults to false
+	EnvStackID             = "CNB_STACK_ID"
 	EnvStackPath           = "CNB_STACK_PATH"
+	EnvStrictPlan          = "CNB_STRICT_PLAN" // defaults to false
 	EnvUID                 = "CNB_USER_ID"
-	EnvUseDaemon           = "CNB_USE_DAEMON" // defaults to false
+	EnvUseDaemon           = "CNB_USE_DAEMON"          // defaults to false
+	EnvValidateBuildpacks  = "CNB_VALIDATE_BUILDPACKS" // defaults to false
 )
 
 var flagSet = flag.NewFlagSet("lifecycle", flag.ExitOnError)
@@ -80,21 +101,71 @@ func FlagAppDir(appDir *string) {
 }
 
 func FlagBuildpacksDir(buildpacksDir *string) {
-	flagSet.StringVar(buildpacksDir, "buildpacks", EnvOrDefault(EnvBuildpacksDir, DefaultBuildpacksDir), "path to buildpacks directory")
+	flagSet.StringVar(buildpacksDir, "buildpacks", EnvOrDefault(EnvBuildpacksDir, DefaultBuildpacksDir), "path to buildpacks directory, or tgz:<dir> of buildpack tarballs, or oci:<dir> of an OCI image layout")
 }
 
 func FlagCacheDir(cacheDir *string) {
-	flagSet.StringVar(cacheDir, "cache-dir", os.Getenv(EnvCacheDir), "path to cache directory")
+	flagSet.StringVar(cacheDir, "cache-dir", os.Getenv(EnvCacheDir), "path to cache directory, shared:<dir> of a cache shared by many apps, or oci:<dir> of an OCI image layout or oci:<file>.tar of an archive of one")
 }
 
 func FlagCacheImage(cacheImage *string) {
 	flagSet.StringVar(cacheImage, "cache-image", os.Getenv(EnvCacheImage), "cache image tag name")
 }
 
+func FlagCacheKey(cacheKey *string) {
+	flagSet.StringVar(cacheKey, "cache-key", os.Getenv(EnvCacheKey), "key of the app in a shared cache directory")
+}
+
+func FlagCacheLockTimeout(timeout *time.Duration) {
+	flagSet.DurationVar(timeout, "cache-lock-timeout", durationEnv(EnvCacheLockTimeout), "maximum duration to wait for another build to release the cache directory")
+}
+
+func FlagCacheMaxAge(maxAge *time.Duration) {
+	flagSet.DurationVar(maxAge, "cache-max-age", durationEnv(EnvCacheMaxAge), "maximum time since a layer in the cache directory was cached")
+}
+
+func FlagCacheMaxSize(maxSize *int64) {
+	*maxSize = byteSizeEnv(EnvCacheMaxSize)
+	flagSet.Var((*byteSize)(maxSize), "cache-max-size", "maximum total size of the layers in the cache directory, in bytes or with a K, M, G or T suffix")
+}
+
+func FlagDetectCacheDir(detectCacheDir *string) {
+	flagSet.StringVar(detectCacheDir, "detect-cache-dir", os.Getenv(EnvDetectCacheDir), "path to detect cache directory")
+}
+
+func FlagDetectConcurrency(concurrency *int) {
+	flagSet.IntVar(concurrency, "detect-concurrency", intEnv(EnvDetectConcurrency), "maximum number of buildpacks to detect at once")
+}
+
+func FlagDetectTimeout(timeout *time.Duration) {
+	flagSet.DurationVar(timeout, "detect-timeout", durationEnv(EnvDetectTimeout), "maximum duration of each buildpack's detect")
+}
+
+func FlagDetectReportPath(detectReportPath *string) {
+	flagSet.StringVar(detectReportPath, "detect-report", os.Getenv(EnvDetectReportPath), "path to write detect-report.toml")
+}
+
+func FlagDryRun(dryRun *bool) {
+	flagSet.BoolVar(dryRun, "dry-run", BoolEnv(EnvDryRun), "resolve buildpacks and print the build plan for each without building")
+}
+
+func FlagEnvPolicyPath(envPolicyPath *string) {
+	flagSet.StringVar(envPolicyPath, "env-policy", os.Getenv(EnvEnvPolicyPath), "path to env policy file restricting platform env vars and naming secrets")
+}
+
+func FlagExplainFormat(format *string) {
+	flagSet.StringVar(format, "format", "text", "explain output format (text or dot)")
+}
+
 func FlagGID(gid *int) {
 	flagSet.IntVar(gid, "gid", intEnv(EnvGID), "GID of user's group in the stack's build and run images")
 }
 
+func FlagGroupHint(groupHint *string) {
+	flagSet.StringVar(groupHint, "group-hint", os.Getenv(EnvGroupHint), "index of the group to detect, or ID of a buildpack it contains")
+	flagSet.StringVar(groupHint, "prefer-group", os.Getenv(EnvGroupHint), "alias for -group-hint")
+}
+
 func FlagGroupPath(groupPath *string) {
 	flagSet.StringVar(groupPath, "group", EnvOrDefault(EnvGroupPath, PlaceholderGroupPath), "path to group.toml")
 }
@@ -123,6 +194,10 @@ func FlagOrderPath(orderPath *string) {
 	flagSet.StringVar(orderPath, "order", EnvOrDefault(EnvOrderPath, DefaultOrderPath), "path to order.toml")
 }
 
+func FlagOutputFormat(format *string) {
+	flagSet.StringVar(format, "output-format", os.Getenv(EnvOutputFormat), "buildpack output format (empty for unchanged output, prefixed or json)")
+}
+
 func FlagPlanPath(planPath *string) {
 	flagSet.StringVar(planPath, "plan", EnvOrDefault(EnvPlanPath, PlaceholderPlanPath), "path to plan.toml")
 }
@@ -147,10 +222,26 @@ func DefaultReportPath(platformAPI, layersDir string) string {
 	return defaultPath(DefaultReportFile, platformAPI, layersDir)
 }
 
+func FlagResume(resume *bool) {
+	flagSet.BoolVar(resume, "resume", BoolEnv(EnvResume), "skip buildpacks that finished in a previous, failed build run with -resume")
+}
+
 func FlagRunImage(runImage *string) {
 	flagSet.StringVar(runImage, "run-image", os.Getenv(EnvRunImage), "reference to run image")
 }
 
+func FlagSBOMDir(sbomDir *string) {
+	flagSet.StringVar(sbomDir, "sbom-dir", os.Getenv(EnvSBOMDir), "path to output directory for CycloneDX and SPDX documents describing the BOM")
+}
+
+func FlagSBOMLayer(sbomLayer *bool) {
+	flagSet.BoolVar(sbomLayer, "sbom-layer", BoolEnv(EnvSBOMLayer), "add the SBOM output directory to the image as a layer")
+}
+
+func FlagSkipDetectCache(skip *bool) {
+	flagSet.BoolVar(skip, "skip-detect-cache", BoolEnv(EnvSkipDetectCache), "re-run detection instead of using results in the detect cache")
+}
+
 func FlagSkipLayers(skip *bool) {
 	flagSet.BoolVar(skip, "skip-layers", BoolEnv(EnvSkipLayers), "do not provide layer metadata to buildpacks")
 }
@@ -159,10 +250,18 @@ func FlagSkipRestore(skip *bool) {
 	flagSet.BoolVar(skip, "skip-restore", BoolEnv(EnvSkipRestore), "do not restore layers or layer metadata")
 }
 
+func FlagStackID(stackID *string) {
+	flagSet.StringVar(stackID, "stack-id", os.Getenv(EnvStackID), "ID of the stack buildpacks must support")
+}
+
 func FlagStackPath(stackPath *string) {
 	flagSet.StringVar(stackPath, "stack", EnvOrDefault(EnvStackPath, DefaultStackPath), "path to stack.toml")
 }
 
+func FlagStrictPlan(strict *bool) {
+	flagSet.BoolVar(strict, "strict-plan", BoolEnv(EnvStrictPlan), "fail the build if a build plan entry is not met by any buildpack")
+}
+
 func FlagTags(tags *StringSlice) {
 	flagSet.Var(tags, "tag", "additional tags")
 }
@@ -175,6 +274,10 @@ func FlagUseDaemon(use *bool) {
 	flagSet.BoolVar(use, "daemon", BoolEnv(EnvUseDaemon), "export to docker daemon")
 }
 
+func FlagValidateBuildpacks(validate *bool) {
+	flagSet.BoolVar(validate, "validate-buildpacks", BoolEnv(EnvValidateBuildpacks), "validate buildpacks and their stacks before detecting")
+}
+
 func FlagVersion(version *bool) {
 	flagSet.BoolVar(version, "version", false, "show version")
 }
@@ -210,6 +313,38 @@ func (s *StringSlice) Set(value string) error {
 	return nil
 }
 
+// byteSize is a size in bytes, given as an integer with an optional K, M, G or T suffix for powers of 1024.
+type byteSize int64
+
+func (b *byteSize) String() string {
+	return strconv.FormatInt(int64(*b), 10)
+}
+
+func (b *byteSize) Set(value string) error {
+	size, err := parseByteSize(value)
+	if err != nil {
+		return err
+	}
+	*b = byteSize(size)
+	return nil
+}
+
+func parseByteSize(value string) (int64, error) {
+	multiplier := int64(1)
+	trimmed := strings.TrimSuffix(strings.ToUpper(value), "B")
+	if trimmed != "" {
+		if i := strings.IndexByte("KMGT", trimmed[len(trimmed)-1]); i >= 0 {
+			multiplier = 1 << (10 * uint(i+1))
+			trimmed = trimmed[:len(trimmed)-1]
+		}
+	}
+	size, err := strconv.ParseInt(trimmed, 10, 64)
+	if err != nil || size < 0 {
+		return 0, fmt.Errorf("invalid size '%s'", value)
+	}
+	return size * multiplier, nil
+}
+
 func intEnv(k string) int {
 	v := os.Getenv(k)
 	d, err := strconv.Atoi(v)
@@ -219,6 +354,23 @@ func intEnv(k string) int {
 	return d
 }
 
+func durationEnv(k string) time.Duration {
+	v := os.Getenv(k)
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return 0
+	}
+	return d
+}
+
+func byteSizeEnv(k string) int64 {
+	size, err := parseByteSize(os.Getenv(k))
+	if err != nil {
+		return 0
+	}
+	return size
+}
+
 func BoolEnv(k string) bool {
 	v := os.Getenv(k)
 	b, err := strconv.ParseBool(v)
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/buildpacks/imgutil"
 	"github.com/buildpacks/imgutil/local"
@@ -18,10 +19,12 @@ import (
 
 type analyzeCmd struct {
 	//flags: inputs
-	cacheDir      string
-	cacheImageTag string
-	groupPath     string
-	uid, gid      int
+	cacheDir         string
+	cacheImageTag    string
+	cacheKey         string
+	cacheLockTimeout time.Duration
+	groupPath        string
+	uid, gid         int
 	analyzeArgs
 
 	//flags: paths to write data
@@ -45,6 +48,8 @@ func (a *analyzeCmd) DefineFlags() {
 	cmd.FlagAnalyzedPath(&a.analyzedPath)
 	cmd.FlagCacheDir(&a.cacheDir)
 	cmd.FlagCacheImage(&a.cacheImageTag)
+	cmd.FlagCacheKey(&a.cacheKey)
+	cmd.FlagCacheLockTimeout(&a.cacheLockTimeout)
 	cmd.FlagGroupPath(&a.groupPath)
 	cmd.FlagLayersDir(&a.layersDir)
 	cmd.FlagSkipLayers(&a.skipLayers)
@@ -90,7 +95,7 @@ func (a *analyzeCmd) Privileges() error {
 			return cmd.FailErr(err, "initialize docker client")
 		}
 	}
-	if err := priv.EnsureOwner(a.uid, a.gid, a.layersDir, a.cacheDir); err != nil {
+	if err := priv.EnsureOwner(a.uid, a.gid, a.layersDir, cacheVolume(a.cacheDir)); err != nil {
 		return cmd.FailErr(err, "chown volumes")
 	}
 	if err := priv.RunAs(a.uid, a.gid); err != nil {
@@ -108,10 +113,11 @@ func (a *analyzeCmd) Exec() error {
 		return err
 	}
 
-	cacheStore, err := initCache(a.cacheImageTag, a.cacheDir, a.keychain)
+	cacheStore, err := initCache(a.cacheImageTag, a.cacheDir, a.cacheKey, a.cacheLockTimeout, a.keychain)
 	if err != nil {
 		return cmd.FailErr(err, "initialize cache")
 	}
+	defer closeCache(cacheStore)
 
 	analyzedMD, err := a.analyze(group, cacheStore)
 	if err != nil {
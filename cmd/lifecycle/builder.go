@@ -2,6 +2,7 @@ package main
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -17,6 +18,7 @@ import (
 
 type buildCmd struct {
 	// flags: inputs
+	dryRun    bool
 	groupPath string
 	planPath  string
 	buildArgs
@@ -25,19 +27,30 @@ type buildCmd struct {
 type buildArgs struct {
 	// inputs needed when run by creator
 	buildpacksDir string
-	layersDir     string
-	appDir        string
-	platformDir   string
-	platformAPI   string
+	// buildpackStore is shared with detection when run by creator; if nil, a store for buildpacksDir is used
+	buildpackStore lifecycle.BuildpackStore
+	envPolicyPath  string
+	layersDir      string
+	appDir         string
+	platformDir    string
+	platformAPI    string
+	outputFormat   string
+	resume         bool
+	strictPlan     bool
 }
 
 func (b *buildCmd) DefineFlags() {
 	cmd.FlagBuildpacksDir(&b.buildpacksDir)
+	cmd.FlagDryRun(&b.dryRun)
+	cmd.FlagEnvPolicyPath(&b.envPolicyPath)
 	cmd.FlagGroupPath(&b.groupPath)
 	cmd.FlagPlanPath(&b.planPath)
 	cmd.FlagLayersDir(&b.layersDir)
 	cmd.FlagAppDir(&b.appDir)
 	cmd.FlagPlatformDir(&b.platformDir)
+	cmd.FlagOutputFormat(&b.outputFormat)
+	cmd.FlagResume(&b.resume)
+	cmd.FlagStrictPlan(&b.strictPlan)
 }
 
 func (b *buildCmd) Args(nargs int, args []string) error {
@@ -53,6 +66,10 @@ func (b *buildCmd) Args(nargs int, args []string) error {
 		b.planPath = cmd.DefaultPlanPath(b.platformAPI, b.layersDir)
 	}
 
+	if err := validateOutputFormat(b.outputFormat); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -72,13 +89,50 @@ func (b *buildCmd) Exec() error {
 	if err := verifyBuildpackApis(group); err != nil {
 		return err
 	}
+	if b.dryRun {
+		return b.buildDryRun(group, plan)
+	}
 	return b.build(group, plan)
 }
 
+func (ba buildArgs) buildDryRun(group lifecycle.BuildpackGroup, plan lifecycle.BuildPlan) error {
+	store, cleanup, err := ba.initBuildpackStore()
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	builder := &lifecycle.Builder{
+		Group:          group,
+		Plan:           plan,
+		BuildpackStore: store,
+	}
+	report, err := builder.DryRun()
+	if err != nil {
+		return cmd.FailErrCode(err, cmd.CodeBuildError, "dry run")
+	}
+	for _, bp := range report.Buildpacks {
+		if err := cmd.VerifyBuildpackAPI(bp.Buildpack.String(), bp.Buildpack.API); err != nil {
+			return err
+		}
+	}
+
+	if err := toml.NewEncoder(cmd.Stdout).Encode(report); err != nil {
+		return cmd.FailErr(err, "write dry run report")
+	}
+	return nil
+}
+
 func (ba buildArgs) build(group lifecycle.BuildpackGroup, plan lifecycle.BuildPlan) error {
-	buildpacksDir, err := filepath.Abs(ba.buildpacksDir)
+	store, cleanup, err := ba.initBuildpackStore()
 	if err != nil {
-		return cmd.FailErrCode(err, cmd.CodeBuildError, "build")
+		return err
+	}
+	defer cleanup()
+
+	envPolicy, err := readEnvPolicy(ba.envPolicyPath, ba.platformDir)
+	if err != nil {
+		return err
 	}
 
 	builder := &lifecycle.Builder{
@@ -91,11 +145,23 @@ func (ba buildArgs) build(group lifecycle.BuildpackGroup, plan lifecycle.BuildPl
 		Plan:           plan,
 		Out:            cmd.Stdout,
 		Err:            cmd.Stderr,
-		BuildpackStore: &lifecycle.DirBuildpackStore{Dir: buildpacksDir},
+		BuildpackStore: store,
+		CheckpointPath: filepath.Join(ba.layersDir, "build-checkpoint.toml"),
+		Resume:         ba.resume,
+		OutputFormat:   ba.outputFormat,
+		StrictPlan:     ba.strictPlan,
+		EnvPolicy:      envPolicy,
+		Logger:         cmd.DefaultLogger,
 	}
 	md, err := builder.Build()
 
 	if err != nil {
+		if md != nil {
+			// the stats of a failed build are the ones platforms need most
+			if err := lifecycle.WriteTOML(launch.GetMetadataFilePath(ba.layersDir), md); err != nil {
+				cmd.DefaultLogger.Warnf("Warning: failed to write build metadata: %s", err)
+			}
+		}
 		if err, ok := err.(*lifecycle.Error); ok {
 			if err.Type == lifecycle.ErrTypeBuildpack {
 				return cmd.FailErrCode(err.Cause(), cmd.CodeFailedBuildWithErrors, "build")
@@ -104,12 +170,43 @@ func (ba buildArgs) build(group lifecycle.BuildpackGroup, plan lifecycle.BuildPl
 		return cmd.FailErrCode(err, cmd.CodeBuildError, "build")
 	}
 
+	for _, entry := range md.Unmet {
+		for _, req := range entry.Requires {
+			cmd.DefaultLogger.Warnf("Build plan entry '%s' was not met by any buildpack", req.Name)
+		}
+	}
+
 	if err := lifecycle.WriteTOML(launch.GetMetadataFilePath(ba.layersDir), md); err != nil {
 		return cmd.FailErr(err, "write build metadata")
 	}
 	return nil
 }
 
+// readEnvPolicy reads the env policy at path, if any, and masks the values of its secrets in logs.
+func readEnvPolicy(path, platformDir string) (*lifecycle.EnvPolicy, error) {
+	if path == "" {
+		return nil, nil
+	}
+	policy, err := lifecycle.ReadEnvPolicy(path)
+	if err != nil {
+		return nil, cmd.FailErr(err, "read env policy")
+	}
+	secrets, err := policy.SecretValues(platformDir, os.Environ())
+	if err != nil {
+		return nil, cmd.FailErr(err, "read secrets")
+	}
+	cmd.MaskLogs(lifecycle.NewSecretMasker(secrets))
+	return policy, nil
+}
+
+func validateOutputFormat(format string) error {
+	switch format {
+	case lifecycle.OutputFormatRaw, lifecycle.OutputFormatPrefixed, lifecycle.OutputFormatJSON:
+		return nil
+	}
+	return cmd.FailErrCode(fmt.Errorf("unknown output format '%s', expected '' for unchanged output, 'prefixed' or 'json'", format), cmd.CodeInvalidArgs, "parse arguments")
+}
+
 func (b *buildCmd) readData() (lifecycle.BuildpackGroup, lifecycle.BuildPlan, error) {
 	group, err := lifecycle.ReadGroup(b.groupPath)
 	if err != nil {
@@ -122,3 +219,11 @@ func (b *buildCmd) readData() (lifecycle.BuildpackGroup, lifecycle.BuildPlan, er
 	}
 	return group, plan, nil
 }
+
+// initBuildpackStore returns the store shared by creator, or a new store for buildpacksDir.
+func (ba buildArgs) initBuildpackStore() (lifecycle.BuildpackStore, func(), error) {
+	if ba.buildpackStore != nil {
+		return ba.buildpackStore, func() {}, nil
+	}
+	return initBuildpackStore(ba.buildpacksDir)
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/docker/docker/client"
 	"github.com/google/go-containerregistry/pkg/authn"
@@ -19,11 +20,21 @@ type createCmd struct {
 	buildpacksDir       string
 	cacheDir            string
 	cacheImageTag       string
+	cacheKey            string
+	cacheLockTimeout    time.Duration
+	cacheMaxAge         time.Duration
+	cacheMaxSize        int64
+	detectCacheDir      string
+	detectConcurrency   int
+	detectTimeout       time.Duration
+	envPolicyPath       string
+	groupHint           string
 	imageName           string
 	launchCacheDir      string
 	launcherPath        string
 	layersDir           string
 	orderPath           string
+	outputFormat        string
 	platformAPI         string
 	platformDir         string
 	previousImage       string
@@ -31,13 +42,20 @@ type createCmd struct {
 	projectMetadataPath string
 	registry            string
 	reportPath          string
+	resume              bool
 	runImageRef         string
+	sbomDir             string
+	sbomLayer           bool
 	stackMD             lifecycle.StackMetadata
 	stackPath           string
+	strictPlan          bool
 	uid, gid            int
 	additionalTags      cmd.StringSlice
+	skipDetectCache     bool
 	skipRestore         bool
+	stackID             string
 	useDaemon           bool
+	validateBuildpacks  bool
 
 	//set if necessary before dropping privileges
 	docker   client.CommonAPIClient
@@ -49,19 +67,36 @@ func (c *createCmd) DefineFlags() {
 	cmd.FlagBuildpacksDir(&c.buildpacksDir)
 	cmd.FlagCacheDir(&c.cacheDir)
 	cmd.FlagCacheImage(&c.cacheImageTag)
+	cmd.FlagCacheKey(&c.cacheKey)
+	cmd.FlagCacheLockTimeout(&c.cacheLockTimeout)
+	cmd.FlagCacheMaxAge(&c.cacheMaxAge)
+	cmd.FlagCacheMaxSize(&c.cacheMaxSize)
+	cmd.FlagDetectCacheDir(&c.detectCacheDir)
+	cmd.FlagDetectConcurrency(&c.detectConcurrency)
+	cmd.FlagDetectTimeout(&c.detectTimeout)
+	cmd.FlagEnvPolicyPath(&c.envPolicyPath)
 	cmd.FlagGID(&c.gid)
+	cmd.FlagGroupHint(&c.groupHint)
 	cmd.FlagLaunchCacheDir(&c.launchCacheDir)
 	cmd.FlagLauncherPath(&c.launcherPath)
 	cmd.FlagLayersDir(&c.layersDir)
 	cmd.FlagOrderPath(&c.orderPath)
+	cmd.FlagOutputFormat(&c.outputFormat)
 	cmd.FlagPlatformDir(&c.platformDir)
 	cmd.FlagPreviousImage(&c.previousImage)
 	cmd.FlagReportPath(&c.reportPath)
+	cmd.FlagResume(&c.resume)
 	cmd.FlagRunImage(&c.runImageRef)
+	cmd.FlagSBOMDir(&c.sbomDir)
+	cmd.FlagSBOMLayer(&c.sbomLayer)
+	cmd.FlagSkipDetectCache(&c.skipDetectCache)
 	cmd.FlagSkipRestore(&c.skipRestore)
+	cmd.FlagStackID(&c.stackID)
 	cmd.FlagStackPath(&c.stackPath)
+	cmd.FlagStrictPlan(&c.strictPlan)
 	cmd.FlagUID(&c.uid)
 	cmd.FlagUseDaemon(&c.useDaemon)
+	cmd.FlagValidateBuildpacks(&c.validateBuildpacks)
 	cmd.FlagTags(&c.additionalTags)
 	cmd.FlagProjectMetadataPath(&c.projectMetadataPath)
 	cmd.FlagProcessType(&c.processType)
@@ -81,6 +116,7 @@ func (c *createCmd) Args(nargs int, args []string) error {
 	if c.cacheImageTag == "" && c.cacheDir == "" {
 		cmd.DefaultLogger.Warn("Not restoring or caching layer data, no cache flag specified.")
 	}
+	checkCacheLimits(c.cacheImageTag, c.cacheDir, &c.cacheMaxSize, &c.cacheMaxAge)
 
 	if c.previousImage == "" {
 		c.previousImage = c.imageName
@@ -98,6 +134,10 @@ func (c *createCmd) Args(nargs int, args []string) error {
 		c.reportPath = cmd.DefaultReportPath(c.platformAPI, c.layersDir)
 	}
 
+	if err := validateOutputFormat(c.outputFormat); err != nil {
+		return err
+	}
+
 	var err error
 	c.stackMD, c.runImageRef, c.registry, err = resolveStack(c.imageName, c.stackPath, c.runImageRef)
 	if err != nil {
@@ -121,7 +161,7 @@ func (c *createCmd) Privileges() error {
 			return cmd.FailErr(err, "initialize docker client")
 		}
 	}
-	if err := priv.EnsureOwner(c.uid, c.gid, c.cacheDir, c.launchCacheDir, c.layersDir); err != nil {
+	if err := priv.EnsureOwner(c.uid, c.gid, cacheVolume(c.cacheDir), c.launchCacheDir, c.layersDir, c.sbomDir); err != nil {
 		return cmd.FailErr(err, "chown volumes")
 	}
 	if err := priv.RunAs(c.uid, c.gid); err != nil {
@@ -134,19 +174,42 @@ func (c *createCmd) Privileges() error {
 }
 
 func (c *createCmd) Exec() error {
-	cacheStore, err := initCache(c.cacheImageTag, c.cacheDir, c.keychain)
+	// mask secrets in the output of every phase, not only the build
+	if _, err := readEnvPolicy(c.envPolicyPath, c.platformDir); err != nil {
+		return err
+	}
+
+	cacheStore, err := initCache(c.cacheImageTag, c.cacheDir, c.cacheKey, c.cacheLockTimeout, c.keychain)
+	if err != nil {
+		return err
+	}
+	defer closeCache(cacheStore)
+	limitCache(cacheStore, c.cacheMaxSize, c.cacheMaxAge)
+
+	// buildpacks are extracted once, for both detection and build
+	store, cleanup, err := initBuildpackStore(c.buildpacksDir)
 	if err != nil {
 		return err
 	}
+	defer cleanup()
 
 	cmd.DefaultLogger.Phase("DETECTING")
 	group, plan, err := detectArgs{
-		buildpacksDir: c.buildpacksDir,
-		appDir:        c.appDir,
-		layersDir:     c.layersDir,
-		platformAPI:   c.platformAPI,
-		platformDir:   c.platformDir,
-		orderPath:     c.orderPath,
+		buildpacksDir:  c.buildpacksDir,
+		buildpackStore: store,
+		appDir:         c.appDir,
+		layersDir:      c.layersDir,
+		platformAPI:    c.platformAPI,
+		platformDir:    c.platformDir,
+		orderPath:      c.orderPath,
+		concurrency:    c.detectConcurrency,
+		timeout:        c.detectTimeout,
+		cacheDir:       c.detectCacheDir,
+		skipCache:      c.skipDetectCache,
+		groupHint:      c.groupHint,
+		envPolicyPath:  c.envPolicyPath,
+		validate:       c.validateBuildpacks,
+		stackID:        c.stackID,
 	}.detect()
 	if err != nil {
 		return err
@@ -175,11 +238,16 @@ func (c *createCmd) Exec() error {
 
 	cmd.DefaultLogger.Phase("BUILDING")
 	err = buildArgs{
-		buildpacksDir: c.buildpacksDir,
-		layersDir:     c.layersDir,
-		appDir:        c.appDir,
-		platformAPI:   c.platformAPI,
-		platformDir:   c.platformDir,
+		buildpacksDir:  c.buildpacksDir,
+		buildpackStore: store,
+		envPolicyPath:  c.envPolicyPath,
+		layersDir:      c.layersDir,
+		appDir:         c.appDir,
+		platformAPI:    c.platformAPI,
+		platformDir:    c.platformDir,
+		outputFormat:   c.outputFormat,
+		resume:         c.resume,
+		strictPlan:     c.strictPlan,
 	}.build(group, plan)
 	if err != nil {
 		return err
@@ -188,6 +256,7 @@ func (c *createCmd) Exec() error {
 	cmd.DefaultLogger.Phase("EXPORTING")
 	return exportArgs{
 		appDir:              c.appDir,
+		cacheLockTimeout:    c.cacheLockTimeout,
 		docker:              c.docker,
 		gid:                 c.gid,
 		imageNames:          append([]string{c.imageName}, c.additionalTags...),
@@ -201,6 +270,8 @@ func (c *createCmd) Exec() error {
 		registry:            c.registry,
 		reportPath:          c.reportPath,
 		runImageRef:         c.runImageRef,
+		sbomDir:             c.sbomDir,
+		sbomLayer:           c.sbomLayer,
 		stackMD:             c.stackMD,
 		stackPath:           c.stackPath,
 		uid:                 c.uid,
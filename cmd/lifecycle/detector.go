@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/buildpacks/lifecycle"
 	"github.com/buildpacks/lifecycle/cmd"
@@ -16,18 +17,30 @@ type detectCmd struct {
 	detectArgs
 
 	// flags: paths to write outputs
-	groupPath string
-	planPath  string
+	groupPath        string
+	planPath         string
+	detectReportPath string
 }
 
 type detectArgs struct {
 	// inputs needed when run by creator
 	buildpacksDir string
-	appDir        string
-	layersDir     string
-	platformAPI   string
-	platformDir   string
-	orderPath     string
+	// buildpackStore is shared with the build when run by creator; if nil, a store for buildpacksDir is used
+	buildpackStore lifecycle.BuildpackStore
+	envPolicyPath  string
+	appDir         string
+	layersDir      string
+	platformAPI    string
+	platformDir    string
+	orderPath      string
+	concurrency    int
+	timeout        time.Duration
+	cacheDir       string
+	skipCache      bool
+	groupHint      string
+	validate       bool
+	stackID        string
+	report         *lifecycle.DetectReport
 }
 
 func (d *detectCmd) DefineFlags() {
@@ -38,6 +51,15 @@ func (d *detectCmd) DefineFlags() {
 	cmd.FlagOrderPath(&d.orderPath)
 	cmd.FlagGroupPath(&d.groupPath)
 	cmd.FlagPlanPath(&d.planPath)
+	cmd.FlagDetectReportPath(&d.detectReportPath)
+	cmd.FlagDetectConcurrency(&d.concurrency)
+	cmd.FlagDetectTimeout(&d.timeout)
+	cmd.FlagDetectCacheDir(&d.cacheDir)
+	cmd.FlagSkipDetectCache(&d.skipCache)
+	cmd.FlagGroupHint(&d.groupHint)
+	cmd.FlagValidateBuildpacks(&d.validate)
+	cmd.FlagStackID(&d.stackID)
+	cmd.FlagEnvPolicyPath(&d.envPolicyPath)
 }
 
 func (d *detectCmd) Args(nargs int, args []string) error {
@@ -65,7 +87,15 @@ func (d *detectCmd) Privileges() error {
 }
 
 func (d *detectCmd) Exec() error {
+	if d.detectReportPath != "" {
+		d.report = &lifecycle.DetectReport{}
+	}
 	group, plan, err := d.detect()
+	if d.report != nil {
+		if err := lifecycle.WriteTOML(d.detectReportPath, d.report); err != nil {
+			return cmd.FailErr(err, "write detect report")
+		}
+	}
 	if err != nil {
 		return err
 	}
@@ -77,7 +107,22 @@ func (da detectArgs) detect() (lifecycle.BuildpackGroup, lifecycle.BuildPlan, er
 	if err != nil {
 		return lifecycle.BuildpackGroup{}, lifecycle.BuildPlan{}, cmd.FailErr(err, "read buildpack order file")
 	}
-	if err := da.verifyBuildpackApis(order); err != nil {
+	store, cleanup, err := da.initBuildpackStore()
+	if err != nil {
+		return lifecycle.BuildpackGroup{}, lifecycle.BuildPlan{}, err
+	}
+	defer cleanup()
+	if err := verifyOrderBuildpackApis(order, store); err != nil {
+		return lifecycle.BuildpackGroup{}, lifecycle.BuildPlan{}, err
+	}
+	if da.validate {
+		if err := validateBuildpacks(order, store, da.stackID); err != nil {
+			return lifecycle.BuildpackGroup{}, lifecycle.BuildPlan{}, err
+		}
+	}
+
+	envPolicy, err := readEnvPolicy(da.envPolicyPath, da.platformDir)
+	if err != nil {
 		return lifecycle.BuildpackGroup{}, lifecycle.BuildPlan{}, err
 	}
 
@@ -87,12 +132,19 @@ func (da detectArgs) detect() (lifecycle.BuildpackGroup, lifecycle.BuildPlan, er
 		return lifecycle.BuildpackGroup{}, lifecycle.BuildPlan{}, cmd.FailErr(err, "read full env")
 	}
 	group, plan, err := order.Detect(&lifecycle.DetectConfig{
-		FullEnv:       fullEnv,
-		ClearEnv:      envv.List(),
-		AppDir:        da.appDir,
-		PlatformDir:   da.platformDir,
-		BuildpacksDir: da.buildpacksDir,
-		Logger:        cmd.DefaultLogger,
+		FullEnv:        fullEnv,
+		ClearEnv:       envv.List(),
+		AppDir:         da.appDir,
+		PlatformDir:    da.platformDir,
+		BuildpackStore: store,
+		Logger:         cmd.DefaultLogger,
+		Report:         da.report,
+		Concurrency:    da.concurrency,
+		Timeout:        da.timeout,
+		CacheDir:       da.cacheDir,
+		RefreshCache:   da.skipCache,
+		GroupHint:      da.groupHint,
+		EnvPolicy:      envPolicy,
 	})
 	if err != nil {
 		switch err := err.(type) {
@@ -105,6 +157,9 @@ func (da detectArgs) detect() (lifecycle.BuildpackGroup, lifecycle.BuildPlan, er
 			case lifecycle.ErrTypeBuildpack:
 				cmd.DefaultLogger.Error("No buildpack groups passed detection.")
 				return lifecycle.BuildpackGroup{}, lifecycle.BuildPlan{}, cmd.FailErrCode(err, cmd.CodeFailedDetectWithErrors, "detect")
+			case lifecycle.ErrTypeDetectTimeout:
+				cmd.DefaultLogger.Error("No buildpack groups passed detection.")
+				return lifecycle.BuildpackGroup{}, lifecycle.BuildPlan{}, cmd.FailErrCode(err, cmd.CodeFailedDetectWithTimeout, "detect")
 			default:
 				return lifecycle.BuildpackGroup{}, lifecycle.BuildPlan{}, cmd.FailErrCode(err, cmd.CodeDetectError, "detect")
 			}
@@ -116,10 +171,10 @@ func (da detectArgs) detect() (lifecycle.BuildpackGroup, lifecycle.BuildPlan, er
 	return group, plan, nil
 }
 
-func (da detectArgs) verifyBuildpackApis(order lifecycle.BuildpackOrder) error {
+func verifyOrderBuildpackApis(order lifecycle.BuildpackOrder, store lifecycle.BuildpackStore) error {
 	for _, group := range order {
 		for _, bp := range group.Group {
-			bpTOML, err := bp.Lookup(da.buildpacksDir)
+			bpTOML, err := bp.LookupIn(store)
 			if err != nil {
 				return cmd.FailErr(err, fmt.Sprintf("lookup buildpack.toml for buildpack '%s'", bp.String()))
 			}
@@ -141,3 +196,11 @@ func (d *detectCmd) writeData(group lifecycle.BuildpackGroup, plan lifecycle.Bui
 	}
 	return nil
 }
+
+// initBuildpackStore returns the store shared by creator, or a new store for buildpacksDir.
+func (da detectArgs) initBuildpackStore() (lifecycle.BuildpackStore, func(), error) {
+	if da.buildpackStore != nil {
+		return da.buildpackStore, func() {}, nil
+	}
+	return initBuildpackStore(da.buildpacksDir)
+}
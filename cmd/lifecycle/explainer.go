@@ -0,0 +1,59 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/BurntSushi/toml"
+
+	"github.com/buildpacks/lifecycle"
+	"github.com/buildpacks/lifecycle/cmd"
+)
+
+type explainCmd struct {
+	// flags: inputs
+	detectReportPath string
+	format           string
+}
+
+func (e *explainCmd) DefineFlags() {
+	cmd.FlagDetectReportPath(&e.detectReportPath)
+	cmd.FlagExplainFormat(&e.format)
+}
+
+func (e *explainCmd) Args(nargs int, args []string) error {
+	if nargs != 0 {
+		return cmd.FailErrCode(errors.New("received unexpected arguments"), cmd.CodeInvalidArgs, "parse arguments")
+	}
+	if e.detectReportPath == "" {
+		return cmd.FailErrCode(errors.New("-detect-report is required"), cmd.CodeInvalidArgs, "parse arguments")
+	}
+	if e.format != "text" && e.format != "dot" {
+		return cmd.FailErrCode(fmt.Errorf("unknown format '%s', expected 'text' or 'dot'", e.format), cmd.CodeInvalidArgs, "parse arguments")
+	}
+	return nil
+}
+
+func (e *explainCmd) Privileges() error {
+	return nil
+}
+
+func (e *explainCmd) Exec() error {
+	var report lifecycle.DetectReport
+	if _, err := toml.DecodeFile(e.detectReportPath, &report); err != nil {
+		return cmd.FailErr(err, "read detect report")
+	}
+	explanation, err := report.Explain()
+	if err != nil {
+		return cmd.FailErr(err, "explain build plan")
+	}
+	if e.format == "dot" {
+		err = explanation.WriteDOT(cmd.Stdout)
+	} else {
+		err = explanation.WriteText(cmd.Stdout)
+	}
+	if err != nil {
+		return cmd.FailErr(err, "write explanation")
+	}
+	return nil
+}
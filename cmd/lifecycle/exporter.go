@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/buildpacks/imgutil"
@@ -30,6 +31,9 @@ type exportCmd struct {
 	//flags: inputs
 	cacheDir              string
 	cacheImageTag         string
+	cacheKey              string
+	cacheMaxAge           time.Duration
+	cacheMaxSize          int64
 	groupPath             string
 	deprecatedRunImageRef string
 	exportArgs
@@ -41,6 +45,7 @@ type exportCmd struct {
 type exportArgs struct {
 	// inputs needed when run by creator
 	appDir              string
+	cacheLockTimeout    time.Duration
 	imageNames          []string
 	launchCacheDir      string
 	launcherPath        string
@@ -51,6 +56,8 @@ type exportArgs struct {
 	registry            string
 	reportPath          string
 	runImageRef         string
+	sbomDir             string
+	sbomLayer           bool
 	stackMD             lifecycle.StackMetadata
 	stackPath           string
 	useDaemon           bool
@@ -66,6 +73,10 @@ func (e *exportCmd) DefineFlags() {
 	cmd.FlagAppDir(&e.appDir)
 	cmd.FlagCacheDir(&e.cacheDir)
 	cmd.FlagCacheImage(&e.cacheImageTag)
+	cmd.FlagCacheKey(&e.cacheKey)
+	cmd.FlagCacheLockTimeout(&e.cacheLockTimeout)
+	cmd.FlagCacheMaxAge(&e.cacheMaxAge)
+	cmd.FlagCacheMaxSize(&e.cacheMaxSize)
 	cmd.FlagGID(&e.gid)
 	cmd.FlagGroupPath(&e.groupPath)
 	cmd.FlagLaunchCacheDir(&e.launchCacheDir)
@@ -75,6 +86,8 @@ func (e *exportCmd) DefineFlags() {
 	cmd.FlagProjectMetadataPath(&e.projectMetadataPath)
 	cmd.FlagReportPath(&e.reportPath)
 	cmd.FlagRunImage(&e.runImageRef)
+	cmd.FlagSBOMDir(&e.sbomDir)
+	cmd.FlagSBOMLayer(&e.sbomLayer)
 	cmd.FlagStackPath(&e.stackPath)
 	cmd.FlagUID(&e.uid)
 	cmd.FlagUseDaemon(&e.useDaemon)
@@ -96,6 +109,7 @@ func (e *exportCmd) Args(nargs int, args []string) error {
 	if e.cacheImageTag == "" && e.cacheDir == "" {
 		cmd.DefaultLogger.Warn("Will not cache data, no cache flag specified.")
 	}
+	checkCacheLimits(e.cacheImageTag, e.cacheDir, &e.cacheMaxSize, &e.cacheMaxAge)
 
 	if err := image.ValidateDestinationTags(e.useDaemon, e.imageNames...); err != nil {
 		return cmd.FailErrCode(err, cmd.CodeInvalidArgs, "validate image tag(s)")
@@ -153,7 +167,7 @@ func (e *exportCmd) Privileges() error {
 			return cmd.FailErr(err, "initialize docker client")
 		}
 	}
-	if err := priv.EnsureOwner(e.uid, e.gid, e.cacheDir, e.launchCacheDir); err != nil {
+	if err := priv.EnsureOwner(e.uid, e.gid, cacheVolume(e.cacheDir), e.launchCacheDir, e.sbomDir); err != nil {
 		return cmd.FailErr(err, "chown volumes")
 	}
 	if err := priv.RunAs(e.uid, e.gid); err != nil {
@@ -171,10 +185,12 @@ func (e *exportCmd) Exec() error {
 		return err
 	}
 
-	cacheStore, err := initCache(e.cacheImageTag, e.cacheDir, e.keychain)
+	cacheStore, err := initCache(e.cacheImageTag, e.cacheDir, e.cacheKey, e.cacheLockTimeout, e.keychain)
 	if err != nil {
 		cmd.DefaultLogger.Infof("no stack metadata found at path '%s', stack metadata will not be exported\n", e.stackPath)
 	}
+	limitCache(cacheStore, e.cacheMaxSize, e.cacheMaxAge)
+	defer closeCache(cacheStore)
 
 	return e.export(group, cacheStore, e.analyzedMD)
 }
@@ -242,6 +258,8 @@ func (ea exportArgs) export(group lifecycle.BuildpackGroup, cacheStore lifecycle
 		OrigMetadata:       analyzedMD.Metadata,
 		Project:            projectMD,
 		RunImageRef:        runImageID,
+		SBOMDir:            ea.sbomDir,
+		SBOMLayer:          ea.sbomLayer,
 		Stack:              ea.stackMD,
 		WorkingImage:       appImage,
 	})
@@ -286,7 +304,7 @@ func (ea exportArgs) initDaemonAppImage(analyzedMD lifecycle.AnalyzedMetadata) (
 	}
 
 	if ea.launchCacheDir != "" {
-		volumeCache, err := cache.NewVolumeCache(ea.launchCacheDir)
+		volumeCache, err := cache.NewVolumeCacheWithTimeout(ea.launchCacheDir, ea.cacheLockTimeout)
 		if err != nil {
 			return nil, "", cmd.FailErr(err, "create launch cache")
 		}
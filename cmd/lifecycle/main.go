@@ -1,9 +1,13 @@
 package main
 
 import (
+	"errors"
+	"io"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 
@@ -61,6 +65,17 @@ func subcommand() {
 		cmd.Run(&rebaseCmd{}, true)
 	case "create":
 		cmd.Run(&createCmd{}, true)
+	case "explain":
+		cmd.Run(&explainCmd{}, true)
+	case "validate-buildpacks":
+		cmd.Run(&validateCmd{}, true)
+	case "cache":
+		if len(os.Args) < 3 || os.Args[2] != "prune" {
+			cmd.Exit(cmd.FailCode(cmd.CodeInvalidArgs, "unknown cache command:", strings.Join(os.Args[2:], " ")))
+		}
+		// drop the cache command so that the flags following it are parsed
+		os.Args = append([]string{os.Args[0], "cache prune"}, os.Args[3:]...)
+		cmd.Run(&pruneCmd{}, true)
 	default:
 		cmd.Exit(cmd.FailCode(cmd.CodeInvalidArgs, "unknown phase:", phase))
 	}
@@ -80,7 +95,34 @@ func verifyBuildpackApis(group lifecycle.BuildpackGroup) error {
 	return nil
 }
 
-func initCache(cacheImageTag, cacheDir string, keychain authn.Keychain) (lifecycle.Cache, error) {
+// initBuildpackStore resolves buildpacks from a directory of unpacked buildpacks, or from
+// buildpack tarballs or an OCI image layout when buildpacksDir is prefixed with "tgz:" or "oci:".
+// The returned func removes the scratch directory that buildpacks are extracted to.
+func initBuildpackStore(buildpacksDir string) (lifecycle.BuildpackStore, func(), error) {
+	if !strings.HasPrefix(buildpacksDir, "tgz:") && !strings.HasPrefix(buildpacksDir, "oci:") {
+		dir, err := filepath.Abs(buildpacksDir)
+		if err != nil {
+			return nil, nil, cmd.FailErr(err, "resolve buildpacks directory")
+		}
+		return &lifecycle.DirBuildpackStore{Dir: dir}, func() {}, nil
+	}
+
+	scratchDir, err := ioutil.TempDir("", "buildpacks.")
+	if err != nil {
+		return nil, nil, cmd.FailErr(err, "create buildpacks scratch directory")
+	}
+	cleanup := func() { os.RemoveAll(scratchDir) }
+	if dir := strings.TrimPrefix(buildpacksDir, "tgz:"); dir != buildpacksDir {
+		return &lifecycle.TarballBuildpackStore{Dir: dir, ScratchDir: scratchDir}, cleanup, nil
+	}
+	return &lifecycle.OCILayoutBuildpackStore{Dir: strings.TrimPrefix(buildpacksDir, "oci:"), ScratchDir: scratchDir}, cleanup, nil
+}
+
+// initCache returns the cache in the image cacheImageTag, or in cacheDir, which is shared by many apps
+// if it is prefixed with "shared:", or which is an OCI image layout if it is prefixed with "oci:".
+// In a shared cache, the app's layers are found by cacheKey.
+// If another build is using cacheDir, initCache waits up to lockTimeout for it to finish.
+func initCache(cacheImageTag, cacheDir, cacheKey string, lockTimeout time.Duration, keychain authn.Keychain) (lifecycle.Cache, error) {
 	var (
 		cacheStore lifecycle.Cache
 		err        error
@@ -90,11 +132,66 @@ func initCache(cacheImageTag, cacheDir string, keychain authn.Keychain) (lifecyc
 		if err != nil {
 			return nil, cmd.FailErr(err, "create image cache")
 		}
+	} else if dir := strings.TrimPrefix(cacheDir, "shared:"); dir != cacheDir {
+		if cacheKey == "" {
+			return nil, cmd.FailErrCode(errors.New("-cache-key is required with a shared cache directory"), cmd.CodeInvalidArgs, "parse arguments")
+		}
+		cacheStore, err = cache.NewSharedCache(dir, cacheKey)
+		if err != nil {
+			return nil, cmd.FailErr(err, "create shared cache")
+		}
+	} else if dir := strings.TrimPrefix(cacheDir, "oci:"); dir != cacheDir {
+		cacheStore, err = cache.NewOCILayoutCacheWithTimeout(dir, lockTimeout)
+		if err != nil {
+			return nil, cmd.FailErr(err, "create OCI layout cache")
+		}
 	} else if cacheDir != "" {
-		cacheStore, err = cache.NewVolumeCache(cacheDir)
+		cacheStore, err = cache.NewVolumeCacheWithTimeout(cacheDir, lockTimeout)
 		if err != nil {
 			return nil, cmd.FailErr(err, "create volume cache")
 		}
 	}
 	return cacheStore, nil
 }
+
+// cacheVolume returns the directory of cacheDir to chown, without the prefix of an OCI layout cache.
+// For an OCI layout archive, it returns the directory holding the archive, where its lock and the rewritten archive
+// are created.
+// A shared cache is not chowned, as it holds the layers of other apps, which may be built as other users.
+func cacheVolume(cacheDir string) string {
+	if strings.HasPrefix(cacheDir, "shared:") {
+		return ""
+	}
+	dir := strings.TrimPrefix(cacheDir, "oci:")
+	if dir != cacheDir && strings.HasSuffix(dir, ".tar") {
+		return filepath.Dir(dir)
+	}
+	return dir
+}
+
+// closeCache releases the lock that a cache in a directory holds until it is committed, if it wasn't committed.
+func closeCache(cacheStore lifecycle.Cache) {
+	if closer, ok := cacheStore.(io.Closer); ok {
+		closer.Close()
+	}
+}
+
+// checkCacheLimits ignores -cache-max-size and -cache-max-age unless the cache is a plain cache directory,
+// the only cache that supports them.
+func checkCacheLimits(cacheImageTag, cacheDir string, maxSize *int64, maxAge *time.Duration) {
+	if *maxSize <= 0 && *maxAge <= 0 {
+		return
+	}
+	if cacheImageTag != "" || strings.HasPrefix(cacheDir, "shared:") || strings.HasPrefix(cacheDir, "oci:") {
+		cmd.DefaultLogger.Warn("Ignoring -cache-max-size and -cache-max-age, not supported by cache images, shared caches or OCI layout caches")
+		*maxSize, *maxAge = 0, 0
+	}
+}
+
+// limitCache sets the limits applied when the layers in a cache directory are committed.
+func limitCache(cacheStore lifecycle.Cache, maxSize int64, maxAge time.Duration) {
+	if volumeCache, ok := cacheStore.(*cache.VolumeCache); ok {
+		volumeCache.MaxSize = maxSize
+		volumeCache.MaxAge = maxAge
+	}
+}
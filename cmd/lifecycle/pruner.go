@@ -0,0 +1,76 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/buildpacks/lifecycle/cache"
+	"github.com/buildpacks/lifecycle/cmd"
+	"github.com/buildpacks/lifecycle/priv"
+)
+
+type pruneCmd struct {
+	// flags: inputs
+	cacheDir         string
+	cacheLockTimeout time.Duration
+	uid, gid         int
+}
+
+func (p *pruneCmd) DefineFlags() {
+	cmd.FlagCacheDir(&p.cacheDir)
+	cmd.FlagCacheLockTimeout(&p.cacheLockTimeout)
+	cmd.FlagGID(&p.gid)
+	cmd.FlagUID(&p.uid)
+}
+
+func (p *pruneCmd) Args(nargs int, args []string) error {
+	if nargs != 0 {
+		return cmd.FailErrCode(errors.New("received unexpected arguments"), cmd.CodeInvalidArgs, "parse arguments")
+	}
+	if p.cacheDir == "" {
+		return cmd.FailErrCode(errors.New("-cache-dir is required"), cmd.CodeInvalidArgs, "parse arguments")
+	}
+	return nil
+}
+
+func (p *pruneCmd) Privileges() error {
+	if err := priv.EnsureOwner(p.uid, p.gid, cacheVolume(p.cacheDir)); err != nil {
+		return cmd.FailErr(err, "chown volumes")
+	}
+	if err := priv.RunAs(p.uid, p.gid); err != nil {
+		return cmd.FailErr(err, fmt.Sprintf("exec as user %d:%d", p.uid, p.gid))
+	}
+	return nil
+}
+
+func (p *pruneCmd) Exec() error {
+	report, err := p.prune()
+	if err != nil {
+		return cmd.FailErr(err, "prune cache")
+	}
+	cmd.DefaultLogger.Infof("Removed %d unreferenced layer(s), reclaiming %d bytes", report.RemovedLayers, report.ReclaimedBytes)
+	cmd.DefaultLogger.Infof("Cache holds %d layer(s) using %d bytes", report.Layers, report.Bytes)
+	return nil
+}
+
+func (p *pruneCmd) prune() (cache.PruneReport, error) {
+	if dir := strings.TrimPrefix(p.cacheDir, "shared:"); dir != p.cacheDir {
+		return cache.PruneSharedCache(dir, cache.DefaultGracePeriod)
+	}
+	if dir := strings.TrimPrefix(p.cacheDir, "oci:"); dir != p.cacheDir {
+		ociCache, err := cache.NewOCILayoutCacheWithTimeout(dir, p.cacheLockTimeout)
+		if err != nil {
+			return cache.PruneReport{}, err
+		}
+		defer ociCache.Close()
+		return ociCache.Prune()
+	}
+	volumeCache, err := cache.NewVolumeCacheWithTimeout(p.cacheDir, p.cacheLockTimeout)
+	if err != nil {
+		return cache.PruneReport{}, err
+	}
+	defer volumeCache.Close()
+	return volumeCache.Prune()
+}
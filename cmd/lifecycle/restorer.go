@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"fmt"
+	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 
@@ -14,12 +15,14 @@ import (
 
 type restoreCmd struct {
 	// flags: inputs
-	cacheDir      string
-	cacheImageTag string
-	groupPath     string
-	layersDir     string
-	platformAPI   string
-	uid, gid      int
+	cacheDir         string
+	cacheImageTag    string
+	cacheKey         string
+	cacheLockTimeout time.Duration
+	groupPath        string
+	layersDir        string
+	platformAPI      string
+	uid, gid         int
 
 	//set before dropping privileges
 	keychain authn.Keychain
@@ -28,6 +31,8 @@ type restoreCmd struct {
 func (r *restoreCmd) DefineFlags() {
 	cmd.FlagCacheDir(&r.cacheDir)
 	cmd.FlagCacheImage(&r.cacheImageTag)
+	cmd.FlagCacheKey(&r.cacheKey)
+	cmd.FlagCacheLockTimeout(&r.cacheLockTimeout)
 	cmd.FlagGroupPath(&r.groupPath)
 	cmd.FlagLayersDir(&r.layersDir)
 	cmd.FlagUID(&r.uid)
@@ -56,7 +61,7 @@ func (r *restoreCmd) Privileges() error {
 		return cmd.FailErr(err, "resolve keychain")
 	}
 
-	if err := priv.EnsureOwner(r.uid, r.gid, r.layersDir, r.cacheDir); err != nil {
+	if err := priv.EnsureOwner(r.uid, r.gid, r.layersDir, cacheVolume(r.cacheDir)); err != nil {
 		return cmd.FailErr(err, "chown volumes")
 	}
 	if err := priv.RunAs(r.uid, r.gid); err != nil {
@@ -73,10 +78,11 @@ func (r *restoreCmd) Exec() error {
 	if err := verifyBuildpackApis(group); err != nil {
 		return err
 	}
-	cacheStore, err := initCache(r.cacheImageTag, r.cacheDir, r.keychain)
+	cacheStore, err := initCache(r.cacheImageTag, r.cacheDir, r.cacheKey, r.cacheLockTimeout, r.keychain)
 	if err != nil {
 		return err
 	}
+	defer closeCache(cacheStore)
 	return restore(r.layersDir, group, cacheStore)
 }
 
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/buildpacks/lifecycle"
+	"github.com/buildpacks/lifecycle/cmd"
+)
+
+type validateCmd struct {
+	// flags: inputs
+	buildpacksDir string
+	orderPath     string
+	stackID       string
+}
+
+func (v *validateCmd) DefineFlags() {
+	cmd.FlagBuildpacksDir(&v.buildpacksDir)
+	cmd.FlagOrderPath(&v.orderPath)
+	cmd.FlagStackID(&v.stackID)
+}
+
+func (v *validateCmd) Args(nargs int, args []string) error {
+	if nargs != 0 {
+		return cmd.FailErrCode(errors.New("received unexpected arguments"), cmd.CodeInvalidArgs, "parse arguments")
+	}
+	return nil
+}
+
+func (v *validateCmd) Privileges() error {
+	return nil
+}
+
+func (v *validateCmd) Exec() error {
+	order, err := lifecycle.ReadOrder(v.orderPath)
+	if err != nil {
+		return cmd.FailErr(err, "read buildpack order file")
+	}
+	store, cleanup, err := initBuildpackStore(v.buildpacksDir)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	return validateBuildpacks(order, store, v.stackID)
+}
+
+func validateBuildpacks(order lifecycle.BuildpackOrder, store lifecycle.BuildpackStore, stackID string) error {
+	problems, err := lifecycle.ValidateOrder(order, store, stackID)
+	if err != nil {
+		return cmd.FailErr(err, "validate buildpacks")
+	}
+	for _, problem := range problems {
+		cmd.DefaultLogger.Error(problem.String())
+	}
+	if len(problems) > 0 {
+		return cmd.FailErrCode(fmt.Errorf("found %d problem(s)", len(problems)), cmd.CodeInvalidBuildpacks, "validate buildpacks")
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"io"
 	"os"
+	"strings"
 	"sync"
 
 	"github.com/apex/log"
@@ -57,23 +58,37 @@ func DisableColor(noColor bool) {
 	Stderr.DisableColors(noColor)
 }
 
+// MaskLogs applies masker to every message logged by DefaultLogger, e.g. to hide the values of secrets.
+func MaskLogs(masker *strings.Replacer) {
+	h := DefaultLogger.Handler.(*handler)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.masker = masker
+}
+
 type handler struct {
 	mu     sync.Mutex
 	writer io.Writer
+	masker *strings.Replacer
 }
 
 func (h *handler) HandleLog(entry *log.Entry) error {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
+	msg := entry.Message
+	if h.masker != nil {
+		msg = h.masker.Replace(msg)
+	}
+
 	var err error
 	switch entry.Level {
 	case log.WarnLevel:
-		_, err = h.writer.Write([]byte(warnStyle(warnLevelText) + appendMissingLineFeed(entry.Message)))
+		_, err = h.writer.Write([]byte(warnStyle(warnLevelText) + appendMissingLineFeed(msg)))
 	case log.ErrorLevel:
-		_, err = h.writer.Write([]byte(errorStyle(errorLevelText) + appendMissingLineFeed(entry.Message)))
+		_, err = h.writer.Write([]byte(errorStyle(errorLevelText) + appendMissingLineFeed(msg)))
 	default:
-		_, err = h.writer.Write([]byte(appendMissingLineFeed(entry.Message)))
+		_, err = h.writer.Write([]byte(appendMissingLineFeed(msg)))
 	}
 	return err
 }
@@ -0,0 +1,148 @@
+package lifecycle
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+)
+
+// detectCacheEntry is the on-disk form of a cached DetectRun.
+type detectCacheEntry struct {
+	planSections
+	Or     planSectionsList `toml:"or"`
+	Stdout []OutputLine     `toml:"stdout"`
+	Stderr []OutputLine     `toml:"stderr"`
+	Code   int              `toml:"code"`
+}
+
+// detectCacheInputs holds the digests shared by the cache keys of every buildpack,
+// computed once before the first buildpack runs /bin/detect.
+type detectCacheInputs struct {
+	once        sync.Once
+	appDigest   string
+	platformEnv string
+	digestErr   error
+}
+
+func (c *DetectConfig) detectCacheKey(b *BuildpackTOML) (string, error) {
+	in := c.cacheInputs
+	in.once.Do(func() {
+		if in.appDigest, in.digestErr = digestDir(c.AppDir); in.digestErr != nil {
+			in.digestErr = errors.Wrap(in.digestErr, "hashing app directory")
+			return
+		}
+		if in.platformEnv, in.digestErr = digestDir(filepath.Join(c.PlatformDir, "env")); in.digestErr != nil {
+			in.digestErr = errors.Wrap(in.digestErr, "hashing platform env")
+		}
+	})
+	if in.digestErr != nil {
+		return "", in.digestErr
+	}
+	h := sha256.New()
+	fmt.Fprintf(h, "id=%s\nversion=%s\napi=%s\nclear-env=%t\napp=%s\nplatform-env=%s\n",
+		b.Buildpack.ID, b.Buildpack.Version, b.API, b.Buildpack.ClearEnv, in.appDigest, in.platformEnv)
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func (c *DetectConfig) readDetectCache(key string) (DetectRun, bool) {
+	var entry detectCacheEntry
+	if _, err := toml.DecodeFile(filepath.Join(c.CacheDir, key+".toml"), &entry); err != nil {
+		if !os.IsNotExist(err) {
+			c.Logger.Warnf("Warning: ignoring unreadable detect cache entry '%s': %s", key, err)
+		}
+		return DetectRun{}, false
+	}
+	return DetectRun{
+		planSections: entry.planSections,
+		Or:           entry.Or,
+		Stdout:       entry.Stdout,
+		Stderr:       entry.Stderr,
+		Code:         entry.Code,
+	}, true
+}
+
+// writeDetectCache stores a conclusive result of /bin/detect.
+// The entry is renamed into place so that concurrent detectors never read a partial entry.
+func (c *DetectConfig) writeDetectCache(key string, run DetectRun) error {
+	if run.Err != nil || (run.Code != CodeDetectPass && run.Code != CodeDetectFail) {
+		return nil
+	}
+	if err := os.MkdirAll(c.CacheDir, 0777); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(c.CacheDir, key+".tmp.")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := toml.NewEncoder(f).Encode(detectCacheEntry{
+		planSections: run.planSections,
+		Or:           run.Or,
+		Stdout:       run.Stdout,
+		Stderr:       run.Stderr,
+		Code:         run.Code,
+	}); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(c.CacheDir, key+".toml"))
+}
+
+// digestDir hashes the path, type, permissions and contents of every file in dir.
+// A missing dir has an empty digest.
+func digestDir(dir string) (string, error) {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return "", nil
+	}
+	h := sha256.New()
+	err := filepath.Walk(dir, func(path string, fi os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s %s\n", filepath.ToSlash(rel), fi.Mode())
+		switch {
+		case fi.Mode()&os.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "-> %s\n", target)
+		case fi.Mode().IsRegular():
+			return hashFile(h, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func hashFile(h hash.Hash, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fh := sha256.New()
+	if _, err := io.Copy(fh, f); err != nil {
+		return err
+	}
+	fmt.Fprintf(h, "sha256:%x\n", fh.Sum(nil))
+	return nil
+}
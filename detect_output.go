@@ -0,0 +1,93 @@
+package lifecycle
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"time"
+)
+
+// OutputLine is a line written to stdout or stderr by /bin/detect, stamped with the time it was read.
+type OutputLine struct {
+	Time time.Time `toml:"time"`
+	Text string    `toml:"text"`
+}
+
+// outputCapture reads lines from a pipe connected to a buildpack process,
+// recording each line and passing it to logf as soon as it is read.
+// If masker is not nil, it is applied to each line before it is recorded.
+type outputCapture struct {
+	r     *os.File
+	w     *os.File
+	lines []OutputLine
+	done  chan struct{}
+}
+
+func captureOutput(logf func(string, ...interface{}), prefix string, masker *strings.Replacer) (*outputCapture, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	oc := &outputCapture{r: r, w: w, done: make(chan struct{})}
+	go func() {
+		defer close(oc.done)
+		br := bufio.NewReader(r)
+		for {
+			line, err := br.ReadString('\n')
+			if line != "" {
+				text := strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+				if masker != nil {
+					text = masker.Replace(text)
+				}
+				oc.lines = append(oc.lines, OutputLine{Time: time.Now(), Text: text})
+				logf("%s%s", prefix, text)
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	return oc, nil
+}
+
+// started closes the write end of the pipe once the child process holds its own copy.
+func (oc *outputCapture) started() {
+	oc.w.Close()
+}
+
+// wait returns the captured lines once the pipe is drained, or once outputDrainTimeout has passed,
+// as children left running in the background may hold the pipe open.
+// If abort is true, the pipe is not drained at all.
+func (oc *outputCapture) wait(abort bool) []OutputLine {
+	oc.w.Close()
+	if !abort {
+		select {
+		case <-oc.done:
+		case <-time.After(outputDrainTimeout):
+		}
+	}
+	oc.r.Close()
+	<-oc.done
+	return oc.lines
+}
+
+// mergeOutput returns the lines of stdout and stderr in the order they were read.
+func mergeOutput(stdout, stderr []OutputLine) []OutputLine {
+	out := make([]OutputLine, 0, len(stdout)+len(stderr))
+	for len(stdout) > 0 && len(stderr) > 0 {
+		if stderr[0].Time.Before(stdout[0].Time) {
+			out, stderr = append(out, stderr[0]), stderr[1:]
+		} else {
+			out, stdout = append(out, stdout[0]), stdout[1:]
+		}
+	}
+	return append(append(out, stdout...), stderr...)
+}
+
+func outputText(lines []OutputLine) string {
+	var texts []string
+	for _, line := range lines {
+		texts = append(texts, line.Text)
+	}
+	return strings.Join(texts, "\n")
+}
@@ -0,0 +1,135 @@
+package lifecycle
+
+import "sort"
+
+// DetectReport records every group and trial evaluated during detection.
+// A nil *DetectReport is valid and records nothing.
+type DetectReport struct {
+	Groups []GroupReport `toml:"groups"`
+}
+
+type GroupReport struct {
+	Buildpacks []BuildpackReport `toml:"buildpacks"`
+	Trials     []TrialReport     `toml:"trials"`
+	Pass       bool              `toml:"pass"`
+}
+
+type BuildpackReport struct {
+	GroupBuildpack
+	Code   int          `toml:"code"`
+	Stdout []OutputLine `toml:"stdout,omitempty"`
+	Stderr []OutputLine `toml:"stderr,omitempty"`
+	Err    string       `toml:"error,omitempty"`
+}
+
+type TrialReport struct {
+	Options      []TrialOptionReport `toml:"options"`
+	Eliminated   []EliminationReport `toml:"eliminated,omitempty"`
+	Dependencies []DependencyReport  `toml:"dependencies,omitempty"`
+	Pass         bool                `toml:"pass"`
+}
+
+// TrialOptionReport identifies the plan a buildpack contributed to a trial.
+// Alternative is 0 for the top-level requires/provides and i for the i-th "or" entry.
+type TrialOptionReport struct {
+	GroupBuildpack
+	Alternative int `toml:"alternative"`
+}
+
+// DependencyReport records which buildpacks provided and required a build plan entry in a passing trial.
+type DependencyReport struct {
+	Name      string           `toml:"name"`
+	Providers []GroupBuildpack `toml:"providers"`
+	Requirers []GroupBuildpack `toml:"requirers"`
+}
+
+type EliminationReport struct {
+	GroupBuildpack
+	UnmetRequire     string   `toml:"unmet-require,omitempty"`
+	RequiredVersion  string   `toml:"required-version,omitempty"`  // version range of UnmetRequire that no provider satisfies
+	ProvidedVersions []string `toml:"provided-versions,omitempty"` // versions of UnmetRequire that its providers provide
+	UnusedProvide    string   `toml:"unused-provide,omitempty"`
+}
+
+func (r *DetectReport) addGroup(done []GroupBuildpack, runs []DetectRun) {
+	if r == nil {
+		return
+	}
+	var bps []BuildpackReport
+	for i, bp := range done {
+		bpReport := BuildpackReport{
+			GroupBuildpack: bp.noAPI().noHomepage(),
+			Code:           runs[i].Code,
+			Stdout:         runs[i].Stdout,
+			Stderr:         runs[i].Stderr,
+		}
+		if runs[i].Err != nil {
+			bpReport.Err = runs[i].Err.Error()
+		}
+		bps = append(bps, bpReport)
+	}
+	r.Groups = append(r.Groups, GroupReport{Buildpacks: bps})
+}
+
+func (r *DetectReport) passGroup() {
+	if r == nil || len(r.Groups) == 0 {
+		return
+	}
+	r.Groups[len(r.Groups)-1].Pass = true
+}
+
+func (r *DetectReport) addTrial(trial detectTrial) {
+	if r == nil || len(r.Groups) == 0 {
+		return
+	}
+	var options []TrialOptionReport
+	for _, option := range trial {
+		options = append(options, TrialOptionReport{
+			GroupBuildpack: option.GroupBuildpack.noAPI().noHomepage(),
+			Alternative:    option.alternative,
+		})
+	}
+	group := &r.Groups[len(r.Groups)-1]
+	group.Trials = append(group.Trials, TrialReport{Options: options})
+}
+
+func (r *DetectReport) currentTrial() *TrialReport {
+	if r == nil || len(r.Groups) == 0 {
+		return nil
+	}
+	group := &r.Groups[len(r.Groups)-1]
+	if len(group.Trials) == 0 {
+		return nil
+	}
+	return &group.Trials[len(group.Trials)-1]
+}
+
+func (r *DetectReport) eliminate(elim EliminationReport) {
+	if trial := r.currentTrial(); trial != nil {
+		elim.GroupBuildpack = elim.GroupBuildpack.noAPI().noHomepage()
+		trial.Eliminated = append(trial.Eliminated, elim)
+	}
+}
+
+func (r *DetectReport) passTrial(deps depMap) {
+	trial := r.currentTrial()
+	if trial == nil {
+		return
+	}
+	trial.Pass = true
+	var names []string
+	for name := range deps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		dep := DependencyReport{Name: name}
+		for _, bp := range deps[name].Providers {
+			dep.Providers = append(dep.Providers, bp.noOpt().noAPI().noHomepage())
+		}
+		for _, bp := range deps[name].requirers {
+			dep.Requirers = append(dep.Requirers, bp.noOpt().noAPI().noHomepage())
+		}
+		trial.Dependencies = append(trial.Dependencies, dep)
+	}
+}
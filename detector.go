@@ -1,14 +1,17 @@
 package lifecycle
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"syscall"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/pkg/errors"
@@ -17,14 +20,16 @@ import (
 )
 
 const (
-	CodeDetectPass  = 0
-	CodeDetectFail  = 100
-	EnvBuildpackDir = "CNB_BUILDPACK_DIR"
+	CodeDetectPass    = 0
+	CodeDetectFail    = 100
+	CodeDetectTimeout = -2 // CodeDetectTimeout indicates that /bin/detect was killed after exceeding DetectConfig.Timeout
+	EnvBuildpackDir   = "CNB_BUILDPACK_DIR"
 )
 
 var (
 	errFailedDetection = errors.New("no buildpacks participating")
 	errBuildpack       = errors.New("buildpack(s) failed with err")
+	errDetectTimeout   = errors.New("buildpack(s) timed out")
 )
 
 type BuildPlan struct {
@@ -85,8 +90,18 @@ func (r *Require) hasTopLevelVersions() bool {
 	return r.Version != ""
 }
 
+// versionConstraint returns the version or version range that providers must satisfy,
+// preferring metadata.version over the deprecated top level version.
+func (r *Require) versionConstraint() string {
+	if version, ok := r.Metadata["version"]; ok {
+		return fmt.Sprintf("%v", version)
+	}
+	return r.Version
+}
+
 type Provide struct {
-	Name string `toml:"name"`
+	Name    string `toml:"name"`
+	Version string `toml:"version,omitempty"`
 }
 
 type DetectConfig struct {
@@ -95,8 +110,62 @@ type DetectConfig struct {
 	AppDir        string
 	PlatformDir   string
 	BuildpacksDir string
-	Logger        Logger
-	runs          *sync.Map
+	// BuildpackStore resolves buildpacks; it defaults to a DirBuildpackStore for BuildpacksDir.
+	BuildpackStore BuildpackStore
+	Logger         Logger
+	Report         *DetectReport
+	// Concurrency limits the number of buildpacks detecting at once; zero means no limit.
+	Concurrency int
+	// Timeout limits the run time of each /bin/detect; zero means no limit.
+	Timeout time.Duration
+	// CacheDir stores results of /bin/detect keyed by buildpack, app directory contents and platform env;
+	// results are not cached if it is empty.
+	CacheDir string
+	// RefreshCache re-runs /bin/detect for every buildpack instead of using results in CacheDir.
+	RefreshCache bool
+	// GroupHint restricts detection to the group of the order at that index, or to the groups
+	// that contain the buildpack with that ID; it is an error if none of them pass.
+	GroupHint string
+	// EnvPolicy names the secret env vars whose values are masked in detect output.
+	EnvPolicy   *EnvPolicy
+	runs        *sync.Map
+	sem         chan struct{}
+	cacheInputs *detectCacheInputs
+	masker      *strings.Replacer
+}
+
+func (c *DetectConfig) init() error {
+	if c.runs == nil {
+		c.runs = &sync.Map{}
+	}
+	if c.BuildpackStore == nil {
+		c.BuildpackStore = &DirBuildpackStore{Dir: c.BuildpacksDir}
+	}
+	if c.sem == nil && c.Concurrency > 0 {
+		c.sem = make(chan struct{}, c.Concurrency)
+	}
+	if c.cacheInputs == nil {
+		c.cacheInputs = &detectCacheInputs{}
+	}
+	if c.masker == nil && c.EnvPolicy != nil {
+		secrets, err := c.EnvPolicy.SecretValues(c.PlatformDir, c.FullEnv)
+		if err != nil {
+			return err
+		}
+		c.masker = NewSecretMasker(secrets)
+	}
+	return nil
+}
+
+func (c *DetectConfig) detectOnce(key string, info *BuildpackTOML) {
+	if _, ok := c.runs.Load(key); ok {
+		return
+	}
+	if c.sem != nil {
+		c.sem <- struct{}{}
+		defer func() { <-c.sem }()
+	}
+	c.runs.Store(key, info.Detect(c))
 }
 
 func (c *DetectConfig) process(done []GroupBuildpack) ([]GroupBuildpack, []BuildPlanEntry, error) {
@@ -107,30 +176,34 @@ func (c *DetectConfig) process(done []GroupBuildpack) ([]GroupBuildpack, []Build
 			return nil, nil, errors.Errorf("missing detection of '%s'", bp)
 		}
 		run := t.(DetectRun)
-		outputLogf := c.Logger.Debugf
 
+		// output is streamed at debug level while /bin/detect runs, so it is only repeated here on error
 		switch run.Code {
 		case CodeDetectPass, CodeDetectFail:
+			if run.Err != nil {
+				c.Logger.Debugf("======== Error: %s ========", bp)
+				c.Logger.Debugf(run.Err.Error())
+			}
 		default:
-			outputLogf = c.Logger.Infof
-		}
-
-		if len(run.Output) > 0 {
-			outputLogf("======== Output: %s ========", bp)
-			outputLogf(string(run.Output))
-		}
-		if run.Err != nil {
-			outputLogf("======== Error: %s ========", bp)
-			outputLogf(run.Err.Error())
+			if len(run.Stdout) > 0 || len(run.Stderr) > 0 {
+				c.Logger.Infof("======== Output: %s ========", bp)
+				c.Logger.Infof(outputText(mergeOutput(run.Stdout, run.Stderr)))
+			}
+			if run.Err != nil {
+				c.Logger.Infof("======== Error: %s ========", bp)
+				c.Logger.Infof(run.Err.Error())
+			}
 		}
 		runs = append(runs, run)
 	}
+	c.Report.addGroup(done, runs)
 
 	c.Logger.Debugf("======== Results ========")
 
 	results := detectResults{}
 	detected := true
 	buildpackErr := false
+	timedOut := false
 	for i, bp := range done {
 		run := runs[i]
 		switch run.Code {
@@ -148,6 +221,10 @@ func (c *DetectConfig) process(done []GroupBuildpack) ([]GroupBuildpack, []Build
 			c.Logger.Infof("err:  %s", bp)
 			buildpackErr = true
 			detected = detected && bp.Optional
+		case CodeDetectTimeout:
+			c.Logger.Infof("timeout: %s", bp)
+			timedOut = true
+			detected = detected && bp.Optional
 		default:
 			c.Logger.Infof("err:  %s (%d)", bp, run.Code)
 			buildpackErr = true
@@ -155,6 +232,9 @@ func (c *DetectConfig) process(done []GroupBuildpack) ([]GroupBuildpack, []Build
 		}
 	}
 	if !detected {
+		if timedOut {
+			return nil, nil, errDetectTimeout
+		}
 		if buildpackErr {
 			return nil, nil, errBuildpack
 		}
@@ -170,6 +250,8 @@ func (c *DetectConfig) process(done []GroupBuildpack) ([]GroupBuildpack, []Build
 		return nil, nil, err
 	}
 
+	c.Report.passGroup()
+
 	if len(done) != len(trial) {
 		c.Logger.Infof("%d of %d buildpacks participating", len(trial), len(done))
 	}
@@ -201,6 +283,7 @@ func (c *DetectConfig) process(done []GroupBuildpack) ([]GroupBuildpack, []Build
 
 func (c *DetectConfig) runTrial(i int, trial detectTrial) (depMap, detectTrial, error) {
 	c.Logger.Debugf("Resolving plan... (try #%d)", i)
+	c.Report.addTrial(trial)
 
 	var deps depMap
 	retry := true
@@ -208,8 +291,17 @@ func (c *DetectConfig) runTrial(i int, trial detectTrial) (depMap, detectTrial,
 		retry = false
 		deps = newDepMap(trial)
 
-		if err := deps.eachUnmetRequire(func(name string, bp GroupBuildpack) error {
+		if err := deps.eachUnmetRequire(func(name string, bp GroupBuildpack, mismatch versionMismatch) error {
 			retry = true
+			c.Report.eliminate(EliminationReport{
+				GroupBuildpack:   bp,
+				UnmetRequire:     name,
+				RequiredVersion:  mismatch.required,
+				ProvidedVersions: mismatch.provided,
+			})
+			if mismatch.required != "" {
+				name = fmt.Sprintf("%s %s (found %s)", name, mismatch.required, strings.Join(mismatch.provided, ", "))
+			}
 			if !bp.Optional {
 				c.Logger.Debugf("fail: %s requires %s", bp, name)
 				return errFailedDetection
@@ -223,6 +315,7 @@ func (c *DetectConfig) runTrial(i int, trial detectTrial) (depMap, detectTrial,
 
 		if err := deps.eachUnmetProvide(func(name string, bp GroupBuildpack) error {
 			retry = true
+			c.Report.eliminate(EliminationReport{GroupBuildpack: bp, UnusedProvide: name})
 			if !bp.Optional {
 				c.Logger.Debugf("fail: %s provides unused %s", bp, name)
 				return errFailedDetection
@@ -239,10 +332,33 @@ func (c *DetectConfig) runTrial(i int, trial detectTrial) (depMap, detectTrial,
 		c.Logger.Debugf("fail: no viable buildpacks in group")
 		return nil, nil, errFailedDetection
 	}
+	c.Report.passTrial(deps)
 	return deps, trial, nil
 }
 
 func (b *BuildpackTOML) Detect(c *DetectConfig) DetectRun {
+	if c.CacheDir == "" {
+		return b.detect(c)
+	}
+	key, err := c.detectCacheKey(b)
+	if err != nil {
+		c.Logger.Warnf("Warning: not caching detection of %s@%s: %s", b.Buildpack.ID, b.Buildpack.Version, err)
+		return b.detect(c)
+	}
+	if !c.RefreshCache {
+		if run, ok := c.readDetectCache(key); ok {
+			c.Logger.Debugf("Using cached detection of %s@%s", b.Buildpack.ID, b.Buildpack.Version)
+			return run
+		}
+	}
+	run := b.detect(c)
+	if err := c.writeDetectCache(key, run); err != nil {
+		c.Logger.Warnf("Warning: failed to cache detection of %s@%s: %s", b.Buildpack.ID, b.Buildpack.Version, err)
+	}
+	return run
+}
+
+func (b *BuildpackTOML) detect(c *DetectConfig) DetectRun {
 	appDir, err := filepath.Abs(c.AppDir)
 	if err != nil {
 		return DetectRun{Code: -1, Err: err}
@@ -262,28 +378,73 @@ func (b *BuildpackTOML) Detect(c *DetectConfig) DetectRun {
 		return DetectRun{Code: -1, Err: err}
 	}
 
-	out := &bytes.Buffer{}
+	// stdout and stderr are read line by line as they are written; on timeout the pipes are abandoned
+	// so that children that inherited them cannot block detection
+	prefix := b.Buildpack.ID + "@" + b.Buildpack.Version
+	stdout, err := captureOutput(c.Logger.Debugf, "["+prefix+"] stdout: ", c.masker)
+	if err != nil {
+		return DetectRun{Code: -1, Err: err}
+	}
+	stderr, err := captureOutput(c.Logger.Debugf, "["+prefix+"] stderr: ", c.masker)
+	if err != nil {
+		stdout.wait(true)
+		return DetectRun{Code: -1, Err: err}
+	}
+
+	ctx := context.Background()
+	if c.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.Timeout)
+		defer cancel()
+	}
 	cmd := exec.Command(
 		filepath.Join(b.Dir, "bin", "detect"),
 		platformDir,
 		planPath,
 	)
+	setProcessGroup(cmd)
 	cmd.Dir = appDir
-	cmd.Stdout = out
-	cmd.Stderr = out
+	cmd.Stdout = stdout.w
+	cmd.Stderr = stderr.w
 	cmd.Env = c.FullEnv
 	if b.Buildpack.ClearEnv {
 		cmd.Env = c.ClearEnv
 	}
 	cmd.Env = append(cmd.Env, EnvBuildpackDir+"="+b.Dir)
 
-	if err := cmd.Run(); err != nil {
-		if err, ok := err.(*exec.ExitError); ok {
+	runErr := cmd.Start()
+	stdout.started()
+	stderr.started()
+	if runErr == nil {
+		// on timeout, kill the children of /bin/detect too, rather than leave them running
+		exited := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				killProcessGroup(cmd)
+			case <-exited:
+			}
+		}()
+		runErr = cmd.Wait()
+		close(exited)
+	}
+	timedOut := ctx.Err() == context.DeadlineExceeded
+	run := DetectRun{Stdout: stdout.wait(timedOut), Stderr: stderr.wait(timedOut)}
+	if timedOut {
+		run.Code = CodeDetectTimeout
+		run.Err = errors.Errorf("detect timed out after %s", c.Timeout)
+		return run
+	}
+	if runErr != nil {
+		run.Code = -1
+		run.Err = runErr
+		if err, ok := runErr.(*exec.ExitError); ok {
 			if status, ok := err.Sys().(syscall.WaitStatus); ok {
-				return DetectRun{Code: status.ExitStatus(), Output: out.Bytes()}
+				run.Code = status.ExitStatus()
+				run.Err = nil
 			}
 		}
-		return DetectRun{Code: -1, Err: err, Output: out.Bytes()}
+		return run
 	}
 	var t DetectRun
 	if _, err := toml.DecodeFile(planPath, &t); err != nil {
@@ -306,7 +467,7 @@ func (b *BuildpackTOML) Detect(c *DetectConfig) DetectRun {
 			c.Logger.Warnf(`Warning: buildpack %s has a "version" key. This key is deprecated in build plan requirements in buildpack API 0.3. "metadata.version" should be used instead`, b.Buildpack.ID)
 		}
 	}
-	t.Output = out.Bytes()
+	t.Stdout, t.Stderr = run.Stdout, run.Stderr
 	return t
 }
 
@@ -315,11 +476,16 @@ type BuildpackGroup struct {
 }
 
 func (bg BuildpackGroup) Detect(c *DetectConfig) (BuildpackGroup, BuildPlan, error) {
-	if c.runs == nil {
-		c.runs = &sync.Map{}
+	if err := c.init(); err != nil {
+		return BuildpackGroup{}, BuildPlan{}, err
+	}
+	if err := c.verifyAcyclic(nil, BuildpackOrder{bg}, map[string]bool{}); err != nil {
+		return BuildpackGroup{}, BuildPlan{}, err
 	}
 	bps, entries, err := bg.detect(nil, &sync.WaitGroup{}, c)
-	if err == errBuildpack {
+	if err == errDetectTimeout {
+		err = NewLifecycleError(err, ErrTypeDetectTimeout)
+	} else if err == errBuildpack {
 		err = NewLifecycleError(err, ErrTypeBuildpack)
 	} else if err == errFailedDetection {
 		err = NewLifecycleError(err, ErrTypeFailedDetection)
@@ -338,7 +504,7 @@ func (bg BuildpackGroup) detect(done []GroupBuildpack, wg *sync.WaitGroup, c *De
 		if hasID(done, bp.ID) {
 			continue
 		}
-		info, err := bp.Lookup(c.BuildpacksDir)
+		info, err := bp.LookupIn(c.BuildpackStore)
 		if err != nil {
 			return nil, nil, err
 		}
@@ -346,15 +512,12 @@ func (bg BuildpackGroup) detect(done []GroupBuildpack, wg *sync.WaitGroup, c *De
 		bp.Homepage = info.Buildpack.Homepage
 		if info.Order != nil {
 			// TODO: double-check slice safety here
-			// FIXME: cyclical references lead to infinite recursion
 			return info.Order.detect(done, bg.Group[i+1:], bp.Optional, wg, c)
 		}
 		done = append(done, bp)
 		wg.Add(1)
 		go func() {
-			if _, ok := c.runs.Load(key); !ok {
-				c.runs.Store(key, info.Detect(c))
-			}
+			c.detectOnce(key, info)
 			wg.Done()
 		}()
 	}
@@ -374,14 +537,31 @@ func (bg BuildpackGroup) append(group ...BuildpackGroup) BuildpackGroup {
 type BuildpackOrder []BuildpackGroup
 
 func (bo BuildpackOrder) Detect(c *DetectConfig) (BuildpackGroup, BuildPlan, error) {
-	if c.runs == nil {
-		c.runs = &sync.Map{}
+	if err := c.init(); err != nil {
+		return BuildpackGroup{}, BuildPlan{}, err
 	}
-	bps, entries, err := bo.detect(nil, nil, false, &sync.WaitGroup{}, c)
-	if err == errBuildpack {
-		err = NewLifecycleError(err, ErrTypeBuildpack)
-	} else if err == errFailedDetection {
-		err = NewLifecycleError(err, ErrTypeFailedDetection)
+	if err := c.verifyAcyclic(nil, bo, map[string]bool{}); err != nil {
+		return BuildpackGroup{}, BuildPlan{}, err
+	}
+	order := bo
+	if c.GroupHint != "" {
+		var err error
+		if order, err = bo.hinted(c); err != nil {
+			return BuildpackGroup{}, BuildPlan{}, err
+		}
+	}
+	bps, entries, err := order.detect(nil, nil, false, &sync.WaitGroup{}, c)
+	if err == errDetectTimeout || err == errBuildpack || err == errFailedDetection {
+		errType := ErrTypeFailedDetection
+		if err == errDetectTimeout {
+			errType = ErrTypeDetectTimeout
+		} else if err == errBuildpack {
+			errType = ErrTypeBuildpack
+		}
+		if c.GroupHint != "" {
+			err = errors.Wrapf(err, "group matching hint '%s' did not pass detection", c.GroupHint)
+		}
+		err = NewLifecycleError(err, errType)
 	}
 	for i := range entries {
 		for j := range entries[i].Requires {
@@ -391,16 +571,60 @@ func (bo BuildpackOrder) Detect(c *DetectConfig) (BuildpackGroup, BuildPlan, err
 	return BuildpackGroup{Group: bps}, BuildPlan{Entries: entries}, err
 }
 
+// hinted returns the groups selected by c.GroupHint, which is either the index of a group
+// or the ID of a buildpack that a group contains, directly or through an order-containing buildpack.
+func (bo BuildpackOrder) hinted(c *DetectConfig) (BuildpackOrder, error) {
+	if i, err := strconv.Atoi(c.GroupHint); err == nil {
+		if i < 0 || i >= len(bo) {
+			return nil, errors.Errorf("group hint '%d' is out of range for order with %d group(s)", i, len(bo))
+		}
+		return BuildpackOrder{bo[i]}, nil
+	}
+	var order BuildpackOrder
+	for _, group := range bo {
+		if c.groupContains(group, c.GroupHint) {
+			order = append(order, group)
+		}
+	}
+	if len(order) == 0 {
+		return nil, errors.Errorf("group hint '%s' matches no group in order", c.GroupHint)
+	}
+	c.Logger.Debugf("Detecting %d group(s) matching hint '%s'", len(order), c.GroupHint)
+	return order, nil
+}
+
+func (c *DetectConfig) groupContains(group BuildpackGroup, id string) bool {
+	for _, bp := range group.Group {
+		if bp.ID == id {
+			return true
+		}
+		info, err := bp.LookupIn(c.BuildpackStore)
+		if err != nil {
+			continue
+		}
+		for _, nested := range info.Order {
+			if c.groupContains(nested, id) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func (bo BuildpackOrder) detect(done, next []GroupBuildpack, optional bool, wg *sync.WaitGroup, c *DetectConfig) ([]GroupBuildpack, []BuildPlanEntry, error) {
 	ngroup := BuildpackGroup{Group: next}
 	buildpackErr := false
+	timedOut := false
 	for _, group := range bo {
 		// FIXME: double-check slice safety here
 		found, plan, err := group.append(ngroup).detect(done, wg, c)
 		if err == errBuildpack {
 			buildpackErr = true
 		}
-		if err == errFailedDetection || err == errBuildpack {
+		if err == errDetectTimeout {
+			timedOut = true
+		}
+		if err == errFailedDetection || err == errBuildpack || err == errDetectTimeout {
 			wg = &sync.WaitGroup{}
 			continue
 		}
@@ -410,12 +634,52 @@ func (bo BuildpackOrder) detect(done, next []GroupBuildpack, optional bool, wg *
 		return ngroup.detect(done, wg, c)
 	}
 
+	if timedOut {
+		return nil, nil, errDetectTimeout
+	}
 	if buildpackErr {
 		return nil, nil, errBuildpack
 	}
 	return nil, nil, errFailedDetection
 }
 
+// verifyAcyclic walks the meta-buildpack expansion path of order and fails if a
+// buildpack appears within its own order. Buildpacks that cannot be looked up are
+// skipped here so that detection reports them only if they are reached.
+func (c *DetectConfig) verifyAcyclic(path []GroupBuildpack, order BuildpackOrder, verified map[string]bool) error {
+	for _, group := range order {
+		for _, bp := range group.Group {
+			for i, p := range path {
+				if p.ID == bp.ID && p.Version == bp.Version {
+					return NewLifecycleError(cycleError(append(path[i:], bp)), ErrTypeCyclicalOrder)
+				}
+			}
+			if verified[bp.String()] {
+				continue
+			}
+			info, err := bp.LookupIn(c.BuildpackStore)
+			if err != nil {
+				continue
+			}
+			if info.Order != nil {
+				if err := c.verifyAcyclic(append(path[:len(path):len(path)], bp), info.Order, verified); err != nil {
+					return err
+				}
+			}
+			verified[bp.String()] = true
+		}
+	}
+	return nil
+}
+
+func cycleError(cycle []GroupBuildpack) error {
+	var ids []string
+	for _, bp := range cycle {
+		ids = append(ids, bp.String())
+	}
+	return errors.Errorf("cyclical buildpack order: %s", strings.Join(ids, " -> "))
+}
+
 func hasID(bps []GroupBuildpack, id string) bool {
 	for _, bp := range bps {
 		if bp.ID == id {
@@ -428,7 +692,8 @@ func hasID(bps []GroupBuildpack, id string) bool {
 type DetectRun struct {
 	planSections
 	Or     planSectionsList `toml:"or"`
-	Output []byte           `toml:"-"`
+	Stdout []OutputLine     `toml:"-"`
+	Stderr []OutputLine     `toml:"-"`
 	Code   int              `toml:"-"`
 	Err    error            `toml:"-"`
 }
@@ -504,7 +769,7 @@ func (r *detectResult) options() []detectOption {
 	for i, sections := range append([]planSections{r.planSections}, r.Or...) {
 		bp := r.GroupBuildpack
 		bp.Optional = bp.Optional && i == len(r.Or)
-		out = append(out, detectOption{bp, sections})
+		out = append(out, detectOption{bp, sections, i})
 	}
 	return out
 }
@@ -536,6 +801,7 @@ func (rs detectResults) runTrialsFrom(prefix detectTrial, f trialFunc) (depMap,
 type detectOption struct {
 	GroupBuildpack
 	planSections
+	alternative int
 }
 
 type detectTrial []detectOption
@@ -554,6 +820,25 @@ type depEntry struct {
 	BuildPlanEntry
 	earlyRequires []GroupBuildpack
 	extraProvides []GroupBuildpack
+	requirers     []GroupBuildpack
+	versions      map[GroupBuildpack]string
+	mismatches    map[GroupBuildpack]versionMismatch // early requires that a provider exists for, at the wrong version
+}
+
+// versionMismatch is a required version range and the versions that the providers provide instead.
+type versionMismatch struct {
+	required string
+	provided []string
+}
+
+func (e depEntry) satisfies(require Require) bool {
+	constraint := require.versionConstraint()
+	for _, p := range e.Providers {
+		if versionsOverlap(e.versions[p], constraint) {
+			return true
+		}
+	}
+	return false
 }
 
 type depMap map[string]depEntry
@@ -574,6 +859,12 @@ func newDepMap(trial detectTrial) depMap {
 func (m depMap) provide(bp GroupBuildpack, provide Provide) {
 	entry := m[provide.Name]
 	entry.extraProvides = append(entry.extraProvides, bp)
+	if provide.Version != "" {
+		if entry.versions == nil {
+			entry.versions = map[GroupBuildpack]string{}
+		}
+		entry.versions[bp] = provide.Version
+	}
 	m[provide.Name] = entry
 }
 
@@ -582,10 +873,21 @@ func (m depMap) require(bp GroupBuildpack, require Require) {
 	entry.Providers = append(entry.Providers, entry.extraProvides...)
 	entry.extraProvides = nil
 
-	if len(entry.Providers) == 0 {
+	if !entry.satisfies(require) {
 		entry.earlyRequires = append(entry.earlyRequires, bp)
+		if len(entry.Providers) != 0 {
+			mismatch := versionMismatch{required: require.versionConstraint()}
+			for _, p := range entry.Providers {
+				mismatch.provided = append(mismatch.provided, entry.versions[p])
+			}
+			if entry.mismatches == nil {
+				entry.mismatches = map[GroupBuildpack]versionMismatch{}
+			}
+			entry.mismatches[bp] = mismatch
+		}
 	} else {
 		entry.Requires = append(entry.Requires, require)
+		entry.requirers = append(entry.requirers, bp)
 	}
 	m[require.Name] = entry
 }
@@ -603,11 +905,11 @@ func (m depMap) eachUnmetProvide(f func(name string, bp GroupBuildpack) error) e
 	return nil
 }
 
-func (m depMap) eachUnmetRequire(f func(name string, bp GroupBuildpack) error) error {
+func (m depMap) eachUnmetRequire(f func(name string, bp GroupBuildpack, mismatch versionMismatch) error) error {
 	for name, entry := range m {
 		if len(entry.earlyRequires) != 0 {
 			for _, bp := range entry.earlyRequires {
-				if err := f(name, bp); err != nil {
+				if err := f(name, bp, entry.mismatches[bp]); err != nil {
 					return err
 				}
 			}
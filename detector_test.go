@@ -5,12 +5,15 @@ import (
 	"os"
 	"path/filepath"
 	"reflect"
+	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/apex/log"
 	"github.com/apex/log/handlers/memory"
 	"github.com/google/go-cmp/cmp"
+	"github.com/google/go-cmp/cmp/cmpopts"
 	"github.com/sclevine/spec"
 	"github.com/sclevine/spec/report"
 
@@ -85,7 +88,32 @@ func testDetector(t *testing.T, when spec.G, it spec.S) {
 				t.Fatalf("Unexpected error:\n%s\n", err)
 			}
 
-			if s := cmp.Diff("\n"+allLogs(logHandler), outputFailureEv1); s != "" {
+			logs := allLogs(logHandler)
+			for _, bp := range []string{"A@v1", "B@v1", "B@v2", "C@v1", "C@v2", "D@v1", "D@v2"} {
+				if !strings.Contains(logs, "["+bp+"] stdout: detect out: "+bp+"\n") ||
+					!strings.Contains(logs, "["+bp+"] stderr: detect err: "+bp+"\n") {
+					t.Fatalf("Missing streamed output of %s:\n%s\n", bp, logs)
+				}
+			}
+
+			if s := cmp.Diff("\n"+withoutStreamedOutput(logs), outputFailureEv1); s != "" {
+				t.Fatalf("Unexpected log:\n%s\n", s)
+			}
+		})
+
+		it("should fail if an order-containing buildpack references itself", func() {
+			_, _, err := lifecycle.BuildpackOrder{
+				{Group: []lifecycle.GroupBuildpack{{ID: "A", Version: "v1"}, {ID: "H", Version: "v1"}}},
+			}.Detect(config)
+			if err, ok := err.(*lifecycle.Error); !ok || err.Type != lifecycle.ErrTypeCyclicalOrder {
+				t.Fatalf("Unexpected error:\n%s\n", err)
+			}
+
+			if s := cmp.Diff(err.Error(), "cyclical buildpack order: H@v1 -> I@v1 -> H@v1"); s != "" {
+				t.Fatalf("Unexpected error:\n%s\n", s)
+			}
+
+			if s := allLogs(logHandler); s != "" {
 				t.Fatalf("Unexpected log:\n%s\n", s)
 			}
 		})
@@ -187,6 +215,83 @@ func testDetector(t *testing.T, when spec.G, it spec.S) {
 			}
 		})
 
+		it("should fail with specific error if any bp detect times out", func() {
+			mkappfile("10", "detect-sleep-B-v1")
+			config.Timeout = 500 * time.Millisecond
+
+			_, _, err := lifecycle.BuildpackOrder{
+				{Group: []lifecycle.GroupBuildpack{
+					{ID: "A", Version: "v1"},
+					{ID: "B", Version: "v1"},
+				}},
+			}.Detect(config)
+			if err, ok := err.(*lifecycle.Error); !ok || err.Type != lifecycle.ErrTypeDetectTimeout {
+				t.Fatalf("Unexpected error:\n%s\n", err)
+			}
+
+			if s := allLogs(logHandler); !strings.HasSuffix(s,
+				"======== Results ========\n"+
+					"pass: A@v1\n"+
+					"timeout: B@v1\n",
+			) {
+				t.Fatalf("Unexpected log:\n%s\n", s)
+			}
+		})
+
+		it("should not wait for background processes that hold the output of bp detect open", func() {
+			h.SkipIf(t, runtime.GOOS == "windows", "background processes are not started on Windows")
+			mkappfile("10", "detect-background-B-v1")
+
+			start := time.Now()
+			_, _, err := lifecycle.BuildpackOrder{
+				{Group: []lifecycle.GroupBuildpack{{ID: "B", Version: "v1"}}},
+			}.Detect(config)
+			h.AssertNil(t, err)
+			if elapsed := time.Since(start); elapsed > 5*time.Second {
+				t.Fatalf("Detect waited %s for a background process", elapsed)
+			}
+		})
+
+		it("should kill the children of a bp detect that times out", func() {
+			h.SkipIf(t, runtime.GOOS == "windows", "process groups are not used on Windows")
+			mkappfile("1", "detect-background-B-v1")
+			mkappfile("10", "detect-sleep-B-v1")
+			config.Timeout = 500 * time.Millisecond
+
+			_, _, err := lifecycle.BuildpackOrder{
+				{Group: []lifecycle.GroupBuildpack{{ID: "B", Version: "v1"}}},
+			}.Detect(config)
+			if err, ok := err.(*lifecycle.Error); !ok || err.Type != lifecycle.ErrTypeDetectTimeout {
+				t.Fatalf("Unexpected error:\n%s\n", err)
+			}
+
+			time.Sleep(1500 * time.Millisecond)
+			h.AssertPathDoesNotExist(t, filepath.Join(config.AppDir, "detect-background-done-B-v1"))
+		})
+
+		it("should detect with limited concurrency", func() {
+			config.Concurrency = 1
+
+			group, _, err := lifecycle.BuildpackOrder{
+				{Group: []lifecycle.GroupBuildpack{
+					{ID: "A", Version: "v1"},
+					{ID: "B", Version: "v1"},
+				}},
+			}.Detect(config)
+			if err != nil {
+				t.Fatalf("Unexpected error:\n%s\n", err)
+			}
+
+			if s := cmp.Diff(group, lifecycle.BuildpackGroup{
+				Group: []lifecycle.GroupBuildpack{
+					{ID: "A", Version: "v1", API: "0.3", Homepage: "Buildpack A Homepage"},
+					{ID: "B", Version: "v1", API: "0.2"},
+				},
+			}); s != "" {
+				t.Fatalf("Unexpected group:\n%s\n", s)
+			}
+		})
+
 		it("should select an appropriate env type", func() {
 			mkappfile("0", "detect-status-A-v1.clear", "detect-status-B-v1")
 
@@ -284,6 +389,204 @@ func testDetector(t *testing.T, when spec.G, it spec.S) {
 			}
 		})
 
+		it("should output detect stdout and stderr in the order they were written", func() {
+			mkappfile("127", "detect-status-B-v1")
+			mkappfile("", "detect-interleave-B-v1")
+			config.Logger = &log.Logger{Handler: logHandler, Level: log.InfoLevel}
+
+			_, _, err := lifecycle.BuildpackOrder{
+				{Group: []lifecycle.GroupBuildpack{{ID: "B", Version: "v1"}}},
+			}.Detect(config)
+			if err, ok := err.(*lifecycle.Error); !ok || err.Type != lifecycle.ErrTypeBuildpack {
+				t.Fatalf("Unexpected error:\n%s\n", err)
+			}
+
+			if s := allLogs(logHandler); !strings.HasPrefix(s,
+				"======== Output: B@v1 ========\n"+
+					"detect err before out\n"+
+					"detect out after err\n"+
+					"detect out: B@v1\n"+
+					"detect err: B@v1\n",
+			) {
+				t.Fatalf("Unexpected log:\n%s\n", s)
+			}
+		})
+
+		it("should mask secrets in detect output", func() {
+			mkappfile("127", "detect-status-B-v1")
+			mkappfile("", "detect-secret-B-v1")
+			config.FullEnv = append(config.FullEnv, "SOME_SECRET=some-secret-value")
+			config.EnvPolicy = &lifecycle.EnvPolicy{Secrets: []string{"SOME_SECRET"}}
+
+			_, _, err := lifecycle.BuildpackOrder{
+				{Group: []lifecycle.GroupBuildpack{{ID: "B", Version: "v1"}}},
+			}.Detect(config)
+			if err, ok := err.(*lifecycle.Error); !ok || err.Type != lifecycle.ErrTypeBuildpack {
+				t.Fatalf("Unexpected error:\n%s\n", err)
+			}
+
+			s := allLogs(logHandler)
+			if strings.Contains(s, "some-secret-value") ||
+				!strings.Contains(s, "[B@v1] stdout: detect secret: ********\n") ||
+				!strings.Contains(s, "[B@v1] stderr: detect secret: ********\n") ||
+				!strings.Contains(s, "======== Output: B@v1 ========\n"+
+					"detect secret: ********\n"+
+					"detect secret: ********\n") {
+				t.Fatalf("Unexpected log:\n%s\n", s)
+			}
+		})
+
+		when("a group hint is set", func() {
+			var order lifecycle.BuildpackOrder
+
+			it.Before(func() {
+				order = lifecycle.BuildpackOrder{
+					{Group: []lifecycle.GroupBuildpack{{ID: "A", Version: "v1"}}},
+					{Group: []lifecycle.GroupBuildpack{{ID: "B", Version: "v1"}}},
+					{Group: []lifecycle.GroupBuildpack{{ID: "C", Version: "v1"}, {ID: "E", Version: "v1", Optional: true}}},
+				}
+			})
+
+			it("should detect the group at the hinted index", func() {
+				config.GroupHint = "1"
+
+				group, _, err := order.Detect(config)
+				h.AssertNil(t, err)
+				if s := cmp.Diff(group, lifecycle.BuildpackGroup{
+					Group: []lifecycle.GroupBuildpack{{ID: "B", Version: "v1", API: "0.2"}},
+				}); s != "" {
+					t.Fatalf("Unexpected group:\n%s\n", s)
+				}
+				h.AssertPathDoesNotExist(t, filepath.Join(config.AppDir, "detect-env-A-v1"))
+			})
+
+			it("should detect groups containing the hinted buildpack ID", func() {
+				config.GroupHint = "F"
+
+				group, _, err := order.Detect(config)
+				h.AssertNil(t, err)
+				if s := cmp.Diff(group, lifecycle.BuildpackGroup{
+					Group: []lifecycle.GroupBuildpack{
+						{ID: "C", Version: "v1", API: "0.2"},
+						{ID: "A", Version: "v1", API: "0.3", Homepage: "Buildpack A Homepage"},
+						{ID: "B", Version: "v1", API: "0.2"},
+					},
+				}); s != "" {
+					t.Fatalf("Unexpected group:\n%s\n", s)
+				}
+			})
+
+			it("should fail instead of falling back if the hinted group fails", func() {
+				mkappfile("100", "detect-status-B-v1")
+				config.GroupHint = "B"
+
+				_, _, err := lifecycle.BuildpackOrder{
+					{Group: []lifecycle.GroupBuildpack{{ID: "B", Version: "v1"}}},
+					{Group: []lifecycle.GroupBuildpack{{ID: "A", Version: "v1"}}},
+				}.Detect(config)
+				if err, ok := err.(*lifecycle.Error); !ok || err.Type != lifecycle.ErrTypeFailedDetection {
+					t.Fatalf("Unexpected error:\n%s\n", err)
+				}
+				h.AssertError(t, err, "group matching hint 'B' did not pass detection: no buildpacks participating")
+				h.AssertPathDoesNotExist(t, filepath.Join(config.AppDir, "detect-env-A-v1"))
+			})
+
+			it("should fail if the hint matches no group", func() {
+				for hint, msg := range map[string]string{
+					"3":       "group hint '3' is out of range for order with 3 group(s)",
+					"missing": "group hint 'missing' matches no group in order",
+				} {
+					config.GroupHint = hint
+					_, _, err := order.Detect(config)
+					h.AssertError(t, err, msg)
+				}
+			})
+		})
+
+		when("a detect cache dir is set", func() {
+			var detectAgain func(refresh bool) (lifecycle.BuildpackGroup, lifecycle.BuildPlan)
+
+			it.Before(func() {
+				config.CacheDir = filepath.Join(tmpDir, "detect-cache")
+				toappfile("\n[[provides]]\n name = \"dep1\"\n[[requires]]\n name = \"dep1\"", "detect-plan-A-v1.toml")
+
+				// /bin/detect writes detect-env-* files to the app dir, which are removed to leave it unchanged
+				detectAgain = func(refresh bool) (lifecycle.BuildpackGroup, lifecycle.BuildPlan) {
+					t.Helper()
+					paths, err := filepath.Glob(filepath.Join(config.AppDir, "detect-env-*"))
+					h.AssertNil(t, err)
+					for _, path := range paths {
+						h.AssertNil(t, os.Remove(path))
+					}
+					logHandler = memory.New()
+					config = &lifecycle.DetectConfig{
+						FullEnv:       config.FullEnv,
+						ClearEnv:      config.ClearEnv,
+						AppDir:        config.AppDir,
+						PlatformDir:   config.PlatformDir,
+						BuildpacksDir: config.BuildpacksDir,
+						Logger:        &log.Logger{Handler: logHandler},
+						CacheDir:      config.CacheDir,
+						RefreshCache:  refresh,
+					}
+					group, plan, err := lifecycle.BuildpackOrder{
+						{Group: []lifecycle.GroupBuildpack{{ID: "A", Version: "v1"}, {ID: "C", Version: "v1", Optional: true}}},
+					}.Detect(config)
+					h.AssertNil(t, err)
+					return group, plan
+				}
+				mkappfile("100", "detect-status-C-v1")
+				detectAgain(false)
+			})
+
+			it("should reuse the results of a previous detection", func() {
+				group, plan := detectAgain(false)
+
+				if s := cmp.Diff(group, lifecycle.BuildpackGroup{
+					Group: []lifecycle.GroupBuildpack{{ID: "A", Version: "v1", API: "0.3", Homepage: "Buildpack A Homepage"}},
+				}); s != "" {
+					t.Fatalf("Unexpected group:\n%s\n", s)
+				}
+				if !hasEntries(plan.Entries, []lifecycle.BuildPlanEntry{
+					{
+						Providers: []lifecycle.GroupBuildpack{{ID: "A", Version: "v1"}},
+						Requires:  []lifecycle.Require{{Name: "dep1"}},
+					},
+				}) {
+					t.Fatalf("Unexpected entries:\n%+v\n", plan.Entries)
+				}
+				h.AssertPathDoesNotExist(t, filepath.Join(config.AppDir, "detect-env-A-v1"))
+				h.AssertPathDoesNotExist(t, filepath.Join(config.AppDir, "detect-env-C-v1"))
+				if s := allLogs(logHandler); !strings.Contains(s, "Using cached detection of A@v1\n") ||
+					!strings.Contains(s, "Using cached detection of C@v1\n") {
+					t.Fatalf("Unexpected log:\n%s\n", s)
+				}
+			})
+
+			it("should re-run detection if the app directory changed", func() {
+				mkappfile("some-data", "some-file")
+				detectAgain(false)
+
+				h.AssertPathExists(t, filepath.Join(config.AppDir, "detect-env-A-v1"))
+			})
+
+			it("should re-run detection if the platform env changed", func() {
+				h.Mkfile(t, "some-value", filepath.Join(platformDir, "env", "SOME_VAR"))
+				detectAgain(false)
+
+				h.AssertPathExists(t, filepath.Join(config.AppDir, "detect-env-A-v1"))
+			})
+
+			it("should re-run detection if the cache is skipped", func() {
+				detectAgain(true)
+
+				h.AssertPathExists(t, filepath.Join(config.AppDir, "detect-env-A-v1"))
+				if s := allLogs(logHandler); strings.Contains(s, "Using cached detection") {
+					t.Fatalf("Unexpected log:\n%s\n", s)
+				}
+			})
+		})
+
 		when("a build plan is employed", func() {
 			it("should return a build plan with matched dependencies", func() {
 				mkappfile("100", "detect-status-C-v1")
@@ -532,6 +835,150 @@ func testDetector(t *testing.T, when spec.G, it spec.S) {
 				}
 			})
 
+			it("should fallback to alternate build plans when no provider satisfies a required version", func() {
+				toappfile("\n[[provides]]\n name = \"node\"\n version = \"12.22.1\"", "detect-plan-A-v1.toml")
+
+				toappfile("\n[[requires]]\n name = \"node\"", "detect-plan-B-v1.toml")
+				toappfile("\n[requires.metadata]\n version = \"^16\"", "detect-plan-B-v1.toml")
+				toappfile("\n[[or]]", "detect-plan-B-v1.toml")
+				toappfile("\n[[or.requires]]\n name = \"node\"", "detect-plan-B-v1.toml")
+				toappfile("\n[or.requires.metadata]\n version = \">=12 <14\"", "detect-plan-B-v1.toml")
+
+				config.Report = &lifecycle.DetectReport{}
+				group, plan, err := lifecycle.BuildpackOrder{
+					{Group: []lifecycle.GroupBuildpack{
+						{ID: "A", Version: "v1"},
+						{ID: "B", Version: "v1"},
+					}},
+				}.Detect(config)
+				if err != nil {
+					t.Fatalf("Unexpected error:\n%s\n", err)
+				}
+
+				if s := cmp.Diff(group, lifecycle.BuildpackGroup{
+					Group: []lifecycle.GroupBuildpack{
+						{ID: "A", Version: "v1", API: "0.3", Homepage: "Buildpack A Homepage"},
+						{ID: "B", Version: "v1", API: "0.2"},
+					},
+				}); s != "" {
+					t.Fatalf("Unexpected group:\n%s\n", s)
+				}
+
+				if !hasEntries(plan.Entries, []lifecycle.BuildPlanEntry{
+					{
+						Providers: []lifecycle.GroupBuildpack{{ID: "A", Version: "v1"}},
+						Requires:  []lifecycle.Require{{Name: "node", Metadata: map[string]interface{}{"version": ">=12 <14"}}},
+					},
+				}) {
+					t.Fatalf("Unexpected entries:\n%+v\n", plan.Entries)
+				}
+
+				if s := allLogs(logHandler); !strings.HasSuffix(s,
+					"Resolving plan... (try #1)\n"+
+						"fail: B@v1 requires node ^16 (found 12.22.1)\n"+
+						"Resolving plan... (try #2)\n"+
+						"A v1\n"+
+						"B v1\n",
+				) {
+					t.Fatalf("Unexpected log:\n%s\n", s)
+				}
+
+				if s := cmp.Diff(config.Report.Groups[0].Trials[0].Eliminated, []lifecycle.EliminationReport{{
+					GroupBuildpack:   lifecycle.GroupBuildpack{ID: "B", Version: "v1"},
+					UnmetRequire:     "node",
+					RequiredVersion:  "^16",
+					ProvidedVersions: []string{"12.22.1"},
+				}}); s != "" {
+					t.Fatalf("Unexpected eliminations:\n%s\n", s)
+				}
+			})
+
+			it("should match required versions against provided version ranges", func() {
+				toappfile("\n[[provides]]\n name = \"node\"\n version = \"14.x\"", "detect-plan-A-v1.toml")
+				toappfile("\n[[provides]]\n name = \"npm\"\n version = \"6.14.0 - 6.14.8\"", "detect-plan-A-v1.toml")
+				toappfile("\n[[requires]]\n name = \"node\"", "detect-plan-B-v1.toml")
+				toappfile("\n[requires.metadata]\n version = \"^14.2\"", "detect-plan-B-v1.toml")
+				toappfile("\n[[requires]]\n name = \"npm\"", "detect-plan-B-v1.toml")
+				toappfile("\n[requires.metadata]\n version = \"~6.14.9 || 6.14.4\"", "detect-plan-B-v1.toml")
+
+				_, plan, err := lifecycle.BuildpackOrder{
+					{Group: []lifecycle.GroupBuildpack{
+						{ID: "A", Version: "v1"},
+						{ID: "B", Version: "v1"},
+					}},
+				}.Detect(config)
+				if err != nil {
+					t.Fatalf("Unexpected error:\n%s\n", err)
+				}
+
+				if !hasEntries(plan.Entries, []lifecycle.BuildPlanEntry{
+					{
+						Providers: []lifecycle.GroupBuildpack{{ID: "A", Version: "v1"}},
+						Requires:  []lifecycle.Require{{Name: "node", Metadata: map[string]interface{}{"version": "^14.2"}}},
+					},
+					{
+						Providers: []lifecycle.GroupBuildpack{{ID: "A", Version: "v1"}},
+						Requires:  []lifecycle.Require{{Name: "npm", Metadata: map[string]interface{}{"version": "~6.14.9 || 6.14.4"}}},
+					},
+				}) {
+					t.Fatalf("Unexpected entries:\n%+v\n", plan.Entries)
+				}
+			})
+
+			it("should record every group and trial in the detect report", func() {
+				mkappfile("100", "detect-status-C-v1")
+				toappfile("\n[[requires]]\n name = \"dep1-missing\"", "detect-plan-A-v1.toml")
+				toappfile("\n[[or]]", "detect-plan-A-v1.toml")
+				toappfile("\n[[or.provides]]\n name = \"dep2-missing\"", "detect-plan-A-v1.toml")
+
+				config.Report = &lifecycle.DetectReport{}
+				_, _, err := lifecycle.BuildpackOrder{
+					{Group: []lifecycle.GroupBuildpack{{ID: "A", Version: "v1"}, {ID: "C", Version: "v1", Optional: true}}},
+					{Group: []lifecycle.GroupBuildpack{{ID: "B", Version: "v1"}}},
+				}.Detect(config)
+				if err != nil {
+					t.Fatalf("Unexpected error:\n%s\n", err)
+				}
+
+				if s := cmp.Diff(config.Report, &lifecycle.DetectReport{
+					Groups: []lifecycle.GroupReport{
+						{
+							Buildpacks: []lifecycle.BuildpackReport{
+								{GroupBuildpack: lifecycle.GroupBuildpack{ID: "A", Version: "v1"}, Stdout: []lifecycle.OutputLine{{Text: "detect out: A@v1"}}, Stderr: []lifecycle.OutputLine{{Text: "detect err: A@v1"}}},
+								{GroupBuildpack: lifecycle.GroupBuildpack{ID: "C", Version: "v1", Optional: true}, Code: 100, Stdout: []lifecycle.OutputLine{{Text: "detect out: C@v1"}}, Stderr: []lifecycle.OutputLine{{Text: "detect err: C@v1"}}},
+							},
+							Trials: []lifecycle.TrialReport{
+								{
+									Options:    []lifecycle.TrialOptionReport{{GroupBuildpack: lifecycle.GroupBuildpack{ID: "A", Version: "v1"}}},
+									Eliminated: []lifecycle.EliminationReport{{GroupBuildpack: lifecycle.GroupBuildpack{ID: "A", Version: "v1"}, UnmetRequire: "dep1-missing"}},
+								},
+								{
+									Options:    []lifecycle.TrialOptionReport{{GroupBuildpack: lifecycle.GroupBuildpack{ID: "A", Version: "v1"}, Alternative: 1}},
+									Eliminated: []lifecycle.EliminationReport{{GroupBuildpack: lifecycle.GroupBuildpack{ID: "A", Version: "v1"}, UnusedProvide: "dep2-missing"}},
+								},
+							},
+						},
+						{
+							Buildpacks: []lifecycle.BuildpackReport{
+								{GroupBuildpack: lifecycle.GroupBuildpack{ID: "B", Version: "v1"}, Stdout: []lifecycle.OutputLine{{Text: "detect out: B@v1"}}, Stderr: []lifecycle.OutputLine{{Text: "detect err: B@v1"}}},
+							},
+							Trials: []lifecycle.TrialReport{
+								{
+									Options: []lifecycle.TrialOptionReport{{GroupBuildpack: lifecycle.GroupBuildpack{ID: "B", Version: "v1"}}},
+									Pass:    true,
+								},
+							},
+							Pass: true,
+						},
+					},
+				}, cmpopts.IgnoreFields(lifecycle.OutputLine{}, "Time")); s != "" {
+					t.Fatalf("Unexpected report:\n%s\n", s)
+				}
+				if line := config.Report.Groups[0].Buildpacks[0].Stdout[0]; line.Time.IsZero() {
+					t.Fatalf("Expected output line to be timestamped:\n%+v\n", line)
+				}
+			})
+
 			it("should convert top level versions to metadata versions", func() {
 				mkappfile("100", "detect-status-C-v1")
 				mkappfile("100", "detect-status-B-v2")
@@ -797,64 +1244,33 @@ func allLogs(logHandler *memory.Handler) string {
 	return h.CleanEndings(out)
 }
 
+// withoutStreamedOutput drops the lines of /bin/detect output, which are logged in nondeterministic order
+func withoutStreamedOutput(logs string) string {
+	var out string
+	for _, line := range strings.SplitAfter(logs, "\n") {
+		if !strings.HasPrefix(line, "[") {
+			out += line
+		}
+	}
+	return out
+}
+
 const outputFailureEv1 = `
-======== Output: A@v1 ========
-detect out: A@v1
-detect err: A@v1
-======== Output: C@v1 ========
-detect out: C@v1
-detect err: C@v1
-======== Output: B@v1 ========
-detect out: B@v1
-detect err: B@v1
 ======== Results ========
 fail: A@v1
 fail: C@v1
 fail: B@v1
-======== Output: A@v1 ========
-detect out: A@v1
-detect err: A@v1
-======== Output: B@v2 ========
-detect out: B@v2
-detect err: B@v2
 ======== Results ========
 fail: A@v1
 fail: B@v2
-======== Output: A@v1 ========
-detect out: A@v1
-detect err: A@v1
-======== Output: C@v2 ========
-detect out: C@v2
-detect err: C@v2
-======== Output: D@v2 ========
-detect out: D@v2
-detect err: D@v2
-======== Output: B@v1 ========
-detect out: B@v1
-detect err: B@v1
 ======== Results ========
 fail: A@v1
 fail: C@v2
 fail: D@v2
 fail: B@v1
-======== Output: A@v1 ========
-detect out: A@v1
-detect err: A@v1
-======== Output: B@v1 ========
-detect out: B@v1
-detect err: B@v1
 ======== Results ========
 fail: A@v1
 fail: B@v1
-======== Output: A@v1 ========
-detect out: A@v1
-detect err: A@v1
-======== Output: D@v1 ========
-detect out: D@v1
-detect err: D@v1
-======== Output: B@v1 ========
-detect out: B@v1
-detect err: B@v1
 ======== Results ========
 fail: A@v1
 fail: D@v1
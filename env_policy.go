@@ -0,0 +1,164 @@
+package lifecycle
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+)
+
+// SecretMask replaces the values of secret env vars in output printed by the lifecycle.
+const SecretMask = "********"
+
+// EnvPolicy restricts which platform env vars each buildpack receives at build time,
+// and names the env vars whose values must not appear in output.
+type EnvPolicy struct {
+	Secrets    []string        `toml:"secrets"`
+	Buildpacks []EnvPolicyRule `toml:"buildpacks"`
+}
+
+// EnvPolicyRule applies to the buildpack with ID, or to every buildpack without a rule of its own if ID is "*".
+// If Allow is not empty, only the platform env vars it lists are provided. Platform env vars in Deny are never provided.
+type EnvPolicyRule struct {
+	ID    string   `toml:"id"`
+	Allow []string `toml:"allow"`
+	Deny  []string `toml:"deny"`
+}
+
+// ReadEnvPolicy reads an EnvPolicy from the TOML file at path.
+func ReadEnvPolicy(path string) (*EnvPolicy, error) {
+	var policy EnvPolicy
+	if _, err := toml.DecodeFile(path, &policy); err != nil {
+		return nil, err
+	}
+	for i, rule := range policy.Buildpacks {
+		if rule.ID == "" {
+			return nil, errors.Errorf("buildpacks[%d] is missing an id", i)
+		}
+	}
+	return &policy, nil
+}
+
+func (p *EnvPolicy) rule(bpID string) *EnvPolicyRule {
+	var wildcard *EnvPolicyRule
+	for i := range p.Buildpacks {
+		switch p.Buildpacks[i].ID {
+		case bpID:
+			return &p.Buildpacks[i]
+		case "*":
+			wildcard = &p.Buildpacks[i]
+		}
+	}
+	return wildcard
+}
+
+func (p *EnvPolicy) allows(bpID, name string) bool {
+	rule := p.rule(bpID)
+	if rule == nil {
+		return true
+	}
+	if len(rule.Allow) > 0 && !containsString(rule.Allow, name) {
+		return false
+	}
+	return !containsString(rule.Deny, name)
+}
+
+// apply returns env without the platform env vars that bpID may not receive.
+// Where such a var is also in baseEnv, the environment without the platform dir, its value from baseEnv is kept.
+func (p *EnvPolicy) apply(bpID string, env, baseEnv []string, platformDir string) ([]string, error) {
+	denied := map[string]bool{}
+	if err := eachPlatformEnvVar(platformDir, func(name string) {
+		if !p.allows(bpID, name) {
+			denied[name] = true
+		}
+	}); err != nil {
+		return nil, err
+	}
+	if len(denied) == 0 {
+		return env, nil
+	}
+	base := map[string]string{}
+	for _, kv := range baseEnv {
+		base[envName(kv)] = kv
+	}
+	var out []string
+	for _, kv := range env {
+		name := envName(kv)
+		if !denied[name] {
+			out = append(out, kv)
+		} else if baseKV, ok := base[name]; ok {
+			out = append(out, baseKV)
+		}
+	}
+	return out, nil
+}
+
+// SecretValues returns the values of the secret env vars, from the platform dir or from environ.
+func (p *EnvPolicy) SecretValues(platformDir string, environ []string) ([]string, error) {
+	var values []string
+	add := func(v string) {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	for _, name := range p.Secrets {
+		contents, err := ioutil.ReadFile(filepath.Join(platformDir, "env", name))
+		if err != nil && !os.IsNotExist(err) {
+			return nil, err
+		}
+		add(string(contents))
+		for _, kv := range environ {
+			if envName(kv) == name {
+				add(strings.TrimPrefix(kv, name+"="))
+			}
+		}
+	}
+	return values, nil
+}
+
+// NewSecretMasker returns a replacer of each of values with SecretMask. It returns nil if values is empty.
+func NewSecretMasker(values []string) *strings.Replacer {
+	if len(values) == 0 {
+		return nil
+	}
+	// replace longer values first, so that a secret containing another is masked in full
+	sorted := append([]string{}, values...)
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+	var oldnew []string
+	for _, v := range sorted {
+		oldnew = append(oldnew, v, SecretMask)
+	}
+	return strings.NewReplacer(oldnew...)
+}
+
+func eachPlatformEnvVar(platformDir string, fn func(name string)) error {
+	fis, err := ioutil.ReadDir(filepath.Join(platformDir, "env"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			fn(fi.Name())
+		}
+	}
+	return nil
+}
+
+func envName(kv string) string {
+	return strings.SplitN(kv, "=", 2)[0]
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package lifecycle_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestEnvPolicy(t *testing.T) {
+	spec.Run(t, "EnvPolicy", testEnvPolicy, spec.Report(report.Terminal{}))
+}
+
+func testEnvPolicy(t *testing.T, when spec.G, it spec.S) {
+	var tmpDir string
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "lifecycle.env-policy")
+		h.AssertNil(t, err)
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	when("#ReadEnvPolicy", func() {
+		it("should read secrets and rules", func() {
+			path := filepath.Join(tmpDir, "env-policy.toml")
+			h.Mkfile(t, `secrets = ["NPM_TOKEN"]`+"\n"+
+				"[[buildpacks]]\n"+`id = "some/npm"`+"\n"+`allow = ["NPM_TOKEN"]`+"\n"+
+				"[[buildpacks]]\n"+`id = "*"`+"\n"+`deny = ["NPM_TOKEN"]`+"\n",
+				path,
+			)
+
+			policy, err := lifecycle.ReadEnvPolicy(path)
+			h.AssertNil(t, err)
+			if s := cmp.Diff(policy, &lifecycle.EnvPolicy{
+				Secrets: []string{"NPM_TOKEN"},
+				Buildpacks: []lifecycle.EnvPolicyRule{
+					{ID: "some/npm", Allow: []string{"NPM_TOKEN"}},
+					{ID: "*", Deny: []string{"NPM_TOKEN"}},
+				},
+			}); s != "" {
+				t.Fatalf("Unexpected policy:\n%s\n", s)
+			}
+		})
+
+		it("should fail if a rule has no id", func() {
+			path := filepath.Join(tmpDir, "env-policy.toml")
+			h.Mkfile(t, "[[buildpacks]]\n"+`deny = ["NPM_TOKEN"]`+"\n", path)
+
+			_, err := lifecycle.ReadEnvPolicy(path)
+			h.AssertError(t, err, "buildpacks[0] is missing an id")
+		})
+	})
+
+	when("#NewSecretMasker", func() {
+		it("should mask secrets that contain other secrets in full", func() {
+			masker := lifecycle.NewSecretMasker([]string{"abc", "abcdef"})
+			h.AssertEq(t, masker.Replace("x abcdef abc"), "x ******** ********")
+		})
+
+		it("should return nil without secrets", func() {
+			if lifecycle.NewSecretMasker(nil) != nil {
+				t.Fatal("Expected nil masker")
+			}
+		})
+	})
+}
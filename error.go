@@ -4,6 +4,8 @@ type ErrorType string
 
 const ErrTypeBuildpack ErrorType = "ERR_BUILDPACK"
 const ErrTypeFailedDetection ErrorType = "ERR_FAILED_DETECTION"
+const ErrTypeCyclicalOrder ErrorType = "ERR_CYCLICAL_ORDER"
+const ErrTypeDetectTimeout ErrorType = "ERR_DETECT_TIMEOUT"
 
 type Error struct {
 	RootError error
@@ -0,0 +1,123 @@
+package lifecycle
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Explanation describes how the passing group in a DetectReport resolved its build plan.
+type Explanation struct {
+	Group        []GroupBuildpack
+	Dependencies []DependencyReport
+	Dropped      []DroppedBuildpack
+}
+
+// DroppedBuildpack is an optional buildpack that is not part of the resolved group.
+type DroppedBuildpack struct {
+	GroupBuildpack
+	Reason string
+}
+
+func (r *DetectReport) Explain() (Explanation, error) {
+	for _, group := range r.Groups {
+		if !group.Pass {
+			continue
+		}
+		for _, trial := range group.Trials {
+			if !trial.Pass {
+				continue
+			}
+			return explainTrial(group, trial), nil
+		}
+	}
+	return Explanation{}, errors.New("no group passed detection")
+}
+
+func explainTrial(group GroupReport, trial TrialReport) Explanation {
+	ex := Explanation{Dependencies: trial.Dependencies}
+	for _, bp := range group.Buildpacks {
+		if reason, dropped := dropReason(bp, trial); dropped {
+			ex.Dropped = append(ex.Dropped, DroppedBuildpack{GroupBuildpack: bp.GroupBuildpack, Reason: reason})
+			continue
+		}
+		ex.Group = append(ex.Group, bp.GroupBuildpack.noOpt())
+	}
+	return ex
+}
+
+func dropReason(bp BuildpackReport, trial TrialReport) (string, bool) {
+	switch bp.Code {
+	case CodeDetectPass:
+	case CodeDetectFail:
+		return "failed detection", true
+	case CodeDetectTimeout:
+		return "timed out during detection", true
+	default:
+		return fmt.Sprintf("errored during detection (%d)", bp.Code), true
+	}
+	for _, elim := range trial.Eliminated {
+		if elim.ID != bp.ID || elim.Version != bp.Version {
+			continue
+		}
+		if elim.RequiredVersion != "" {
+			return fmt.Sprintf("requires %s %s, but found %s", elim.UnmetRequire, elim.RequiredVersion, strings.Join(elim.ProvidedVersions, ", ")), true
+		}
+		if elim.UnmetRequire != "" {
+			return fmt.Sprintf("requires %s, which no buildpack provides", elim.UnmetRequire), true
+		}
+		return fmt.Sprintf("provides %s, which no buildpack requires", elim.UnusedProvide), true
+	}
+	return "", false
+}
+
+// WriteText writes the resolved group, each build plan dependency and the dropped buildpacks.
+func (ex Explanation) WriteText(w io.Writer) error {
+	lines := []string{"Group:"}
+	for _, bp := range ex.Group {
+		lines = append(lines, "  "+bp.String())
+	}
+	lines = append(lines, "Build plan:")
+	for _, dep := range ex.Dependencies {
+		lines = append(lines, fmt.Sprintf("  %s: %s -> %s", dep.Name, joinBuildpacks(dep.Providers), joinBuildpacks(dep.Requirers)))
+	}
+	if len(ex.Dropped) > 0 {
+		lines = append(lines, "Dropped optional buildpacks:")
+		for _, bp := range ex.Dropped {
+			lines = append(lines, fmt.Sprintf("  %s: %s", bp, bp.Reason))
+		}
+	}
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// WriteDOT writes a Graphviz graph with an edge from each provider to each requirer of a dependency.
+func (ex Explanation) WriteDOT(w io.Writer) error {
+	lines := []string{"digraph plan {"}
+	for _, bp := range ex.Group {
+		lines = append(lines, fmt.Sprintf("  %q;", bp.String()))
+	}
+	for _, dep := range ex.Dependencies {
+		for _, provider := range dep.Providers {
+			for _, requirer := range dep.Requirers {
+				lines = append(lines, fmt.Sprintf("  %q -> %q [label=%q];", provider.String(), requirer.String(), dep.Name))
+			}
+		}
+	}
+	for _, bp := range ex.Dropped {
+		lines = append(lines, fmt.Sprintf("  %q [style=dashed, tooltip=%q];", bp.String(), bp.Reason))
+	}
+	lines = append(lines, "}")
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+func joinBuildpacks(bps []GroupBuildpack) string {
+	var out []string
+	for _, bp := range bps {
+		out = append(out, bp.String())
+	}
+	return strings.Join(out, ", ")
+}
@@ -0,0 +1,156 @@
+package lifecycle_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/google/go-cmp/cmp"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestExplain(t *testing.T) {
+	spec.Run(t, "Explain", testExplain, spec.Report(report.Terminal{}))
+}
+
+func testExplain(t *testing.T, when spec.G, it spec.S) {
+	var detectReport *lifecycle.DetectReport
+
+	it.Before(func() {
+		var (
+			a = lifecycle.GroupBuildpack{ID: "A", Version: "v1"}
+			b = lifecycle.GroupBuildpack{ID: "B", Version: "v1"}
+			c = lifecycle.GroupBuildpack{ID: "C", Version: "v1", Optional: true}
+			d = lifecycle.GroupBuildpack{ID: "D", Version: "v1", Optional: true}
+			e = lifecycle.GroupBuildpack{ID: "E", Version: "v1", Optional: true}
+		)
+		detectReport = &lifecycle.DetectReport{
+			Groups: []lifecycle.GroupReport{
+				{
+					Buildpacks: []lifecycle.BuildpackReport{{GroupBuildpack: a, Code: 100}},
+				},
+				{
+					Buildpacks: []lifecycle.BuildpackReport{
+						{GroupBuildpack: a},
+						{GroupBuildpack: b},
+						{GroupBuildpack: c, Code: 100},
+						{GroupBuildpack: d},
+						{GroupBuildpack: e},
+					},
+					Trials: []lifecycle.TrialReport{
+						{
+							Options:    []lifecycle.TrialOptionReport{{GroupBuildpack: a}, {GroupBuildpack: b}, {GroupBuildpack: d}},
+							Eliminated: []lifecycle.EliminationReport{{GroupBuildpack: b, UnmetRequire: "node"}},
+						},
+						{
+							Options: []lifecycle.TrialOptionReport{{GroupBuildpack: a}, {GroupBuildpack: b, Alternative: 1}, {GroupBuildpack: d}, {GroupBuildpack: e}},
+							Eliminated: []lifecycle.EliminationReport{
+								{GroupBuildpack: d, UnusedProvide: "npm"},
+								{GroupBuildpack: e, UnmetRequire: "node", RequiredVersion: "^16", ProvidedVersions: []string{"14.17.0"}},
+							},
+							Dependencies: []lifecycle.DependencyReport{
+								{Name: "node", Providers: []lifecycle.GroupBuildpack{a}, Requirers: []lifecycle.GroupBuildpack{b}},
+							},
+							Pass: true,
+						},
+					},
+					Pass: true,
+				},
+			},
+		}
+	})
+
+	when("#Explain", func() {
+		it("should explain the passing trial of the passing group", func() {
+			ex, err := detectReport.Explain()
+			h.AssertNil(t, err)
+
+			if s := cmp.Diff(ex, lifecycle.Explanation{
+				Group: []lifecycle.GroupBuildpack{{ID: "A", Version: "v1"}, {ID: "B", Version: "v1"}},
+				Dependencies: []lifecycle.DependencyReport{
+					{
+						Name:      "node",
+						Providers: []lifecycle.GroupBuildpack{{ID: "A", Version: "v1"}},
+						Requirers: []lifecycle.GroupBuildpack{{ID: "B", Version: "v1"}},
+					},
+				},
+				Dropped: []lifecycle.DroppedBuildpack{
+					{GroupBuildpack: lifecycle.GroupBuildpack{ID: "C", Version: "v1", Optional: true}, Reason: "failed detection"},
+					{GroupBuildpack: lifecycle.GroupBuildpack{ID: "D", Version: "v1", Optional: true}, Reason: "provides npm, which no buildpack requires"},
+					{GroupBuildpack: lifecycle.GroupBuildpack{ID: "E", Version: "v1", Optional: true}, Reason: "requires node ^16, but found 14.17.0"},
+				},
+			}); s != "" {
+				t.Fatalf("Unexpected explanation:\n%s\n", s)
+			}
+		})
+
+		it("should read a detect report written as TOML", func() {
+			tmpDir, err := ioutil.TempDir("", "lifecycle.explain")
+			h.AssertNil(t, err)
+			defer os.RemoveAll(tmpDir)
+
+			path := filepath.Join(tmpDir, "detect-report.toml")
+			h.AssertNil(t, lifecycle.WriteTOML(path, detectReport))
+			var decoded lifecycle.DetectReport
+			_, err = toml.DecodeFile(path, &decoded)
+			h.AssertNil(t, err)
+
+			if s := cmp.Diff(&decoded, detectReport); s != "" {
+				t.Fatalf("Unexpected report:\n%s\n", s)
+			}
+		})
+
+		it("should fail if no group passed", func() {
+			detectReport.Groups = detectReport.Groups[:1]
+
+			_, err := detectReport.Explain()
+			h.AssertError(t, err, "no group passed detection")
+		})
+	})
+
+	when("#WriteText", func() {
+		it("should write the group, plan and dropped buildpacks", func() {
+			ex, err := detectReport.Explain()
+			h.AssertNil(t, err)
+
+			buf := &bytes.Buffer{}
+			h.AssertNil(t, ex.WriteText(buf))
+			h.AssertEq(t, buf.String(), "Group:\n"+
+				"  A@v1\n"+
+				"  B@v1\n"+
+				"Build plan:\n"+
+				"  node: A@v1 -> B@v1\n"+
+				"Dropped optional buildpacks:\n"+
+				"  C@v1: failed detection\n"+
+				"  D@v1: provides npm, which no buildpack requires\n"+
+				"  E@v1: requires node ^16, but found 14.17.0\n",
+			)
+		})
+	})
+
+	when("#WriteDOT", func() {
+		it("should write a graph from providers to requirers", func() {
+			ex, err := detectReport.Explain()
+			h.AssertNil(t, err)
+
+			buf := &bytes.Buffer{}
+			h.AssertNil(t, ex.WriteDOT(buf))
+			h.AssertEq(t, buf.String(), "digraph plan {\n"+
+				"  \"A@v1\";\n"+
+				"  \"B@v1\";\n"+
+				"  \"A@v1\" -> \"B@v1\" [label=\"node\"];\n"+
+				"  \"C@v1\" [style=dashed, tooltip=\"failed detection\"];\n"+
+				"  \"D@v1\" [style=dashed, tooltip=\"provides npm, which no buildpack requires\"];\n"+
+				"  \"E@v1\" [style=dashed, tooltip=\"requires node ^16, but found 14.17.0\"];\n"+
+				"}\n",
+			)
+		})
+	})
+}
@@ -60,6 +60,10 @@ type ExportOptions struct {
 	Stack              StackMetadata
 	Project            ProjectMetadata
 	DefaultProcessType string
+	// SBOMDir is where CycloneDX and SPDX documents describing the BOM are written; none are written if it is empty.
+	SBOMDir string
+	// SBOMLayer adds SBOMDir to the image as a layer.
+	SBOMLayer bool
 }
 
 type ExportReport struct {
@@ -68,7 +72,8 @@ type ExportReport struct {
 }
 
 type BuildReport struct {
-	BOM []BOMEntry `toml:"bom"`
+	BOM   []BOMEntry       `toml:"bom"`
+	Stats []BuildpackStats `toml:"stats,omitempty"`
 }
 
 type ImageReport struct {
@@ -91,6 +96,13 @@ func (e *Exporter) Export(opts ExportOptions) (ExportReport, error) {
 		return ExportReport{}, errors.Wrapf(err, "app dir absolute path")
 	}
 
+	if opts.SBOMDir != "" {
+		opts.SBOMDir, err = filepath.Abs(opts.SBOMDir)
+		if err != nil {
+			return ExportReport{}, errors.Wrapf(err, "sbom dir absolute path")
+		}
+	}
+
 	meta := LayersMetadata{}
 	meta.RunImage.TopLayer, err = opts.WorkingImage.TopLayer()
 	if err != nil {
@@ -120,6 +132,23 @@ func (e *Exporter) Export(opts ExportOptions) (ExportReport, error) {
 		return ExportReport{}, err
 	}
 
+	report := ExportReport{}
+	report.Build, err = e.makeBuildReport(opts.LayersDir, buildMD.Stats)
+	if err != nil {
+		return ExportReport{}, err
+	}
+
+	if opts.SBOMDir != "" {
+		// report.Build is empty for platform API < 0.5
+		buildBOM, err := e.readBuildBOM(opts.LayersDir)
+		if err != nil {
+			return ExportReport{}, err
+		}
+		if err := e.addSBOM(opts, buildMD.BOM, buildBOM, &meta); err != nil {
+			return ExportReport{}, errors.Wrap(err, "exporting sbom")
+		}
+	}
+
 	if err := e.setLabels(opts, meta, buildMD); err != nil {
 		return ExportReport{}, err
 	}
@@ -141,11 +170,6 @@ func (e *Exporter) Export(opts ExportOptions) (ExportReport, error) {
 		return ExportReport{}, errors.Wrap(err, "setting cmd")
 	}
 
-	report := ExportReport{}
-	report.Build, err = e.makeBuildReport(opts.LayersDir)
-	if err != nil {
-		return ExportReport{}, err
-	}
 	report.Image, err = saveImage(opts.WorkingImage, opts.AdditionalNames, e.Logger)
 	if err != nil {
 		return ExportReport{}, err
@@ -283,6 +307,30 @@ func (e *Exporter) addAppLayers(opts ExportOptions, slices []layers.Slice, meta
 	return nil
 }
 
+func (e *Exporter) addSBOM(opts ExportOptions, launchBOM, buildBOM []BOMEntry, meta *LayersMetadata) error {
+	e.Logger.Infof("Writing SBOM to '%s'", opts.SBOMDir)
+	if err := WriteSBOM(opts.SBOMDir, launchBOM, buildBOM); err != nil {
+		return err
+	}
+	if !opts.SBOMLayer {
+		return nil
+	}
+	layer, err := e.LayerFactory.DirLayer("sbom", opts.SBOMDir)
+	if err != nil {
+		return errors.Wrapf(err, "creating layer '%s'", layer.ID)
+	}
+	var origSHA string
+	if opts.OrigMetadata.SBOM != nil {
+		origSHA = opts.OrigMetadata.SBOM.SHA
+	}
+	sha, err := e.addOrReuseLayer(opts.WorkingImage, layer, origSHA)
+	if err != nil {
+		return err
+	}
+	meta.SBOM = &LayerMetadata{SHA: sha}
+	return nil
+}
+
 func (e *Exporter) setLabels(opts ExportOptions, meta LayersMetadata, buildMD *BuildMetadata) error {
 	data, err := json.Marshal(meta)
 	if err != nil {
@@ -442,10 +490,19 @@ func (e *Exporter) addOrReuseLayer(image imgutil.Image, layer layers.Layer, prev
 	return layer.Digest, image.AddLayerWithDiffID(layer.TarPath, layer.Digest)
 }
 
-func (e *Exporter) makeBuildReport(layersDir string) (BuildReport, error) {
+func (e *Exporter) makeBuildReport(layersDir string, stats []BuildpackStats) (BuildReport, error) {
 	if e.PlatformAPI.Compare(api.MustParse("0.5")) < 0 { // platform API < 0.5
 		return BuildReport{}, nil
 	}
+	bom, err := e.readBuildBOM(layersDir)
+	if err != nil {
+		return BuildReport{}, err
+	}
+	return BuildReport{BOM: bom, Stats: stats}, nil
+}
+
+// readBuildBOM reads the build bill-of-materials that each buildpack wrote to its build.toml.
+func (e *Exporter) readBuildBOM(layersDir string) ([]BOMEntry, error) {
 	var out []BOMEntry
 	for _, bp := range e.Buildpacks {
 		if api.MustParse(bp.API).Compare(api.MustParse("0.5")) < 0 { // buildpack API < 0.5
@@ -454,9 +511,9 @@ func (e *Exporter) makeBuildReport(layersDir string) (BuildReport, error) {
 		var bpBuildReport BuildReport
 		bpBuildTOML := filepath.Join(layersDir, launch.EscapeID(bp.ID), "build.toml")
 		if _, err := toml.DecodeFile(bpBuildTOML, &bpBuildReport); err != nil && !os.IsNotExist(err) {
-			return BuildReport{}, err
+			return nil, err
 		}
 		out = append(out, withBuildpack(bp, bpBuildReport.BOM)...)
 	}
-	return BuildReport{BOM: out}, nil
+	return out, nil
 }
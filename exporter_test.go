@@ -806,6 +806,50 @@ version = "4.5.6"
 				h.AssertEq(t, fakeAppImage.NumberOfAddedLayers(), 6)
 			})
 
+			when("an sbom dir is set", func() {
+				it.Before(func() {
+					opts.SBOMDir = filepath.Join(tmpDir, "sbom")
+					f, err := os.OpenFile(filepath.Join(opts.LayersDir, "config", "metadata.toml"), os.O_APPEND|os.O_WRONLY, 0)
+					h.AssertNil(t, err)
+					_, err = f.WriteString("[[bom]]\n  name = \"some-dep\"\n  [bom.metadata]\n    version = \"v1\"\n  [bom.buildpack]\n    id = \"buildpack.id\"\n    version = \"1.2.3\"\n")
+					h.AssertNil(t, err)
+					h.AssertNil(t, f.Close())
+				})
+
+				it.After(func() {
+					opts.SBOMDir = ""
+					opts.SBOMLayer = false
+				})
+
+				it("writes cyclonedx and spdx documents describing the bom", func() {
+					_, err := exporter.Export(opts)
+					h.AssertNil(t, err)
+
+					for _, file := range []string{lifecycle.SBOMCycloneDXFile, lifecycle.SBOMSPDXFile} {
+						contents, err := ioutil.ReadFile(filepath.Join(opts.SBOMDir, file))
+						h.AssertNil(t, err)
+						h.AssertStringContains(t, string(contents), `"some-dep"`)
+					}
+					h.AssertEq(t, fakeAppImage.NumberOfAddedLayers(), 6)
+				})
+
+				it("adds the sbom dir as a layer if requested", func() {
+					opts.SBOMLayer = true
+
+					_, err := exporter.Export(opts)
+					h.AssertNil(t, err)
+
+					assertHasLayer(t, fakeAppImage, "sbom")
+					assertAddLayerLog(t, logHandler, "sbom")
+
+					metadataJSON, err := fakeAppImage.Label("io.buildpacks.lifecycle.metadata")
+					h.AssertNil(t, err)
+					var meta lifecycle.LayersMetadata
+					h.AssertNil(t, json.Unmarshal([]byte(metadataJSON), &meta))
+					h.AssertEq(t, meta.SBOM, &lifecycle.LayerMetadata{SHA: "sbom-digest"})
+				})
+			})
+
 			it("saves metadata with layer info", func() {
 				_, err := exporter.Export(opts)
 				h.AssertNil(t, err)
@@ -1203,6 +1247,26 @@ version = "4.5.6"
 					})
 				})
 
+				when("build metadata has stats", func() {
+					it.Before(func() {
+						opts.LayersDir = filepath.Join("testdata", "exporter", "build-metadata", "layers")
+					})
+
+					it("adds buildpack stats to the report", func() {
+						report, err := exporter.Export(opts)
+						h.AssertNil(t, err)
+
+						h.AssertEq(t, report.Build.Stats, []lifecycle.BuildpackStats{
+							{
+								Buildpack:   lifecycle.GroupBuildpack{ID: "buildpack.id", Version: "1.2.3"},
+								DurationMS:  1500,
+								MaxRSS:      1048576,
+								LayersBytes: 2048,
+							},
+						})
+					})
+				})
+
 				when("invalid", func() {
 					it.Before(func() {
 						opts.LayersDir = filepath.Join("testdata", "exporter", "build-metadata", "bad-layers")
@@ -1214,6 +1278,31 @@ version = "4.5.6"
 					})
 				})
 			})
+
+			when("platform api < 0.5", func() {
+				it.Before(func() {
+					exporter.PlatformAPI = api.MustParse("0.4")
+					opts.LayersDir = filepath.Join("testdata", "exporter", "build-metadata", "layers")
+					opts.SBOMDir = filepath.Join(tmpDir, "sbom")
+				})
+
+				it.After(func() {
+					opts.SBOMDir = ""
+				})
+
+				it("writes build bom entries to the sbom but not the report", func() {
+					report, err := exporter.Export(opts)
+					h.AssertNil(t, err)
+
+					h.AssertEq(t, len(report.Build.BOM), 0)
+					for _, file := range []string{lifecycle.SBOMCycloneDXFile, lifecycle.SBOMSPDXFile} {
+						contents, err := ioutil.ReadFile(filepath.Join(opts.SBOMDir, file))
+						h.AssertNil(t, err)
+						h.AssertStringContains(t, string(contents), `"dep1"`)
+						h.AssertStringContains(t, string(contents), `"dep2"`)
+					}
+				})
+			})
 		})
 
 		when("buildpack requires an escaped id", func() {
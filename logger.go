@@ -13,3 +13,15 @@ type Logger interface {
 	Error(msg string)
 	Errorf(fmt string, v ...interface{})
 }
+
+// discardLogger is used where a Logger is not provided.
+type discardLogger struct{}
+
+func (discardLogger) Debug(msg string)                    {}
+func (discardLogger) Debugf(fmt string, v ...interface{}) {}
+func (discardLogger) Info(msg string)                     {}
+func (discardLogger) Infof(fmt string, v ...interface{})  {}
+func (discardLogger) Warn(msg string)                     {}
+func (discardLogger) Warnf(fmt string, v ...interface{})  {}
+func (discardLogger) Error(msg string)                    {}
+func (discardLogger) Errorf(fmt string, v ...interface{}) {}
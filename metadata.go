@@ -22,6 +22,9 @@ type BuildMetadata struct {
 	Launcher   LauncherMetadata `toml:"-" json:"launcher"`
 	Processes  []launch.Process `toml:"processes" json:"processes"`
 	Slices     []layers.Slice   `toml:"slices" json:"-"`
+	Stats      []BuildpackStats `toml:"stats,omitempty" json:"-"`
+	// Unmet lists the build plan entries that no buildpack in the group met.
+	Unmet []BuildPlanEntry `toml:"unmet,omitempty" json:"-"`
 }
 
 type LauncherMetadata struct {
@@ -72,6 +75,7 @@ type LayersMetadata struct {
 	Launcher     LayerMetadata             `json:"launcher" toml:"launcher"`
 	ProcessTypes LayerMetadata             `json:"process-types" toml:"process-types"`
 	RunImage     RunImageMetadata          `json:"runImage" toml:"run-image"`
+	SBOM         *LayerMetadata            `json:"sbom,omitempty" toml:"sbom,omitempty"`
 	Stack        StackMetadata             `json:"stack" toml:"stack"`
 }
 
@@ -85,6 +89,7 @@ type LayersMetadataCompat struct {
 	ProcessTypes LayerMetadata             `json:"process-types" toml:"process-types"`
 	Buildpacks   []BuildpackLayersMetadata `json:"buildpacks" toml:"buildpacks"`
 	RunImage     RunImageMetadata          `json:"runImage" toml:"run-image"`
+	SBOM         *LayerMetadata            `json:"sbom,omitempty" toml:"sbom,omitempty"`
 	Stack        StackMetadata             `json:"stack" toml:"stack"`
 }
 
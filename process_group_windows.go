@@ -0,0 +1,17 @@
+package lifecycle
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+// setProcessGroup does nothing on Windows, where killProcessGroup finds children by their parent.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills cmd and the tree of processes it started.
+func killProcessGroup(cmd *exec.Cmd) error {
+	if err := exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run(); err != nil {
+		return cmd.Process.Kill()
+	}
+	return nil
+}
@@ -1,6 +1,10 @@
 package lifecycle
 
 import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+
 	"github.com/pkg/errors"
 	"golang.org/x/sync/errgroup"
 
@@ -61,8 +65,14 @@ func (r *Restorer) Restore(cache Cache) error {
 				}
 			} else {
 				r.Logger.Infof("Restoring data for %q from cache", bpLayer.Identifier())
+				bpLayer := bpLayer
 				g.Go(func() error {
-					return r.restoreLayer(cache, cachedLayer.SHA)
+					verified, err := r.restoreLayer(cache, cachedLayer.SHA)
+					if err != nil || verified {
+						return err
+					}
+					r.Logger.Warnf("Removing %q, cached data does not match sha %q", bpLayer.Identifier(), cachedLayer.SHA)
+					return bpLayer.remove()
 				})
 			}
 		}
@@ -73,17 +83,29 @@ func (r *Restorer) Restore(cache Cache) error {
 	return nil
 }
 
-func (r *Restorer) restoreLayer(cache Cache, sha string) error {
+// restoreLayer extracts the layer with sha from the cache, and reports whether the data it extracted matches sha.
+// An error extracting data that doesn't match sha is not returned, as the data is corrupt rather than unusable.
+func (r *Restorer) restoreLayer(cache Cache, sha string) (bool, error) {
 	// Sanity check to prevent panic.
 	if cache == nil {
-		return errors.New("restoring layer: cache not provided")
+		return false, errors.New("restoring layer: cache not provided")
 	}
 	r.Logger.Debugf("Retrieving data for %q", sha)
 	rc, err := cache.RetrieveLayer(sha)
 	if err != nil {
-		return err
+		return false, err
 	}
 	defer rc.Close()
 
-	return layers.Extract(rc, "")
+	hasher := sha256.New()
+	extractErr := layers.Extract(io.TeeReader(rc, hasher), "")
+	// the tar reader stops at the end of the archive, which may be before the end of the data
+	if _, err := io.Copy(hasher, rc); err != nil && extractErr == nil {
+		return false, errors.Wrapf(err, "reading layer with SHA '%s'", sha)
+	}
+	if digest := fmt.Sprintf("sha256:%x", hasher.Sum(nil)); digest != sha {
+		r.Logger.Debugf("Layer sha: %q, cached data sha: %q", sha, digest)
+		return false, nil
+	}
+	return true, extractErr
 }
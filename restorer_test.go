@@ -1,6 +1,7 @@
 package lifecycle_test
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -301,6 +302,50 @@ func testRestorer(t *testing.T, when spec.G, it spec.S) {
 				})
 			})
 
+			when("there is a cache=true layer with corrupt data in the cache", func() {
+				var layerTarPath string
+
+				it.Before(func() {
+					h.AssertNil(t, writeLayer(layersDir, "buildpack.id", "cache-only", "cache=true", cacheOnlyLayerSHA))
+					var err error
+					layerTarPath, err = testCache.(*cache.VolumeCache).RetrieveLayerFile(cacheOnlyLayerSHA)
+					h.AssertNil(t, err)
+				})
+
+				when("the data is modified", func() {
+					it.Before(func() {
+						contents := h.MustReadFile(t, layerTarPath)
+						contents = bytes.Replace(contents, []byte("echo text from cache-only layer"), []byte("echo text from corrupt layer!!!"), 1)
+						h.AssertNil(t, ioutil.WriteFile(layerTarPath, contents, 0666))
+						h.AssertNil(t, restorer.Restore(testCache))
+					})
+
+					it("removes metadata and sha file", func() {
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only.toml"))
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only.sha"))
+					})
+					it("removes the restored layer data", func() {
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only"))
+					})
+				})
+
+				when("the data is truncated", func() {
+					it.Before(func() {
+						contents := h.MustReadFile(t, layerTarPath)
+						h.AssertNil(t, ioutil.WriteFile(layerTarPath, contents[:len(contents)/2], 0666))
+						h.AssertNil(t, restorer.Restore(testCache))
+					})
+
+					it("removes metadata and sha file", func() {
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only.toml"))
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only.sha"))
+					})
+					it("removes the restored layer data", func() {
+						h.AssertPathDoesNotExist(t, filepath.Join(layersDir, "buildpack.id", "cache-only"))
+					})
+				})
+			})
+
 			when("there is a cache=true layer not in cache", func() {
 				it.Before(func() {
 					meta := "cache=true"
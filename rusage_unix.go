@@ -0,0 +1,20 @@
+// +build linux darwin
+
+package lifecycle
+
+import (
+	"os"
+	"runtime"
+	"syscall"
+)
+
+func maxRSS(state *os.ProcessState) int64 {
+	rusage, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return 0
+	}
+	if runtime.GOOS == "darwin" {
+		return rusage.Maxrss // bytes
+	}
+	return rusage.Maxrss * 1024 // kilobytes
+}
@@ -0,0 +1,8 @@
+package lifecycle
+
+import "os"
+
+// maxRSS is not reported by the rusage of Windows processes.
+func maxRSS(state *os.ProcessState) int64 {
+	return 0
+}
@@ -0,0 +1,257 @@
+package lifecycle
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/buildpacks/imgutil"
+)
+
+const (
+	SBOMCycloneDXFile = "bom.cdx.json"
+	SBOMSPDXFile      = "bom.spdx.json"
+
+	sbomScopeLaunch = "launch"
+	sbomScopeBuild  = "build"
+)
+
+// sbomComponent is a BOM entry with the fields that both SBOM formats understand pulled out of its free-form metadata.
+type sbomComponent struct {
+	Name      string
+	Version   string
+	PURL      string
+	CPE       string
+	Licenses  []string
+	Buildpack GroupBuildpack
+	Scope     string
+}
+
+func sbomComponents(entries []BOMEntry, scope string) []sbomComponent {
+	var out []sbomComponent
+	for _, entry := range entries {
+		c := sbomComponent{
+			Name:      entry.Name,
+			Version:   entry.Version,
+			Buildpack: entry.Buildpack.noOpt().noAPI().noHomepage(),
+			Scope:     scope,
+		}
+		if c.Version == "" {
+			c.Version, _ = entry.Metadata["version"].(string)
+		}
+		c.PURL, _ = entry.Metadata["purl"].(string)
+		c.CPE, _ = entry.Metadata["cpe"].(string)
+		c.Licenses = metadataLicenses(entry.Metadata["licenses"])
+		out = append(out, c)
+	}
+	return out
+}
+
+// metadataLicenses accepts licenses given either as strings or as tables with a type key.
+func metadataLicenses(v interface{}) []string {
+	var licenses []string
+	add := func(l interface{}) {
+		switch l := l.(type) {
+		case string:
+			licenses = append(licenses, l)
+		case map[string]interface{}:
+			if t, ok := l["type"].(string); ok {
+				licenses = append(licenses, t)
+			}
+		}
+	}
+	switch v := v.(type) {
+	case []interface{}:
+		for _, l := range v {
+			add(l)
+		}
+	case []map[string]interface{}:
+		for _, l := range v {
+			add(l)
+		}
+	}
+	return licenses
+}
+
+// WriteSBOM writes CycloneDX and SPDX documents describing the launch and build BOM entries to dir.
+// The documents contain no timestamps or random identifiers, so that they are reproducible.
+func WriteSBOM(dir string, launchBOM, buildBOM []BOMEntry) error {
+	components := append(sbomComponents(launchBOM, sbomScopeLaunch), sbomComponents(buildBOM, sbomScopeBuild)...)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+	if err := writeJSON(filepath.Join(dir, SBOMCycloneDXFile), cycloneDX(components)); err != nil {
+		return err
+	}
+	spdxDoc, err := spdx(components)
+	if err != nil {
+		return err
+	}
+	return writeJSON(filepath.Join(dir, SBOMSPDXFile), spdxDoc)
+}
+
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, append(data, '\n'), 0666)
+}
+
+type cycloneDXDocument struct {
+	BOMFormat   string               `json:"bomFormat"`
+	SpecVersion string               `json:"specVersion"`
+	Version     int                  `json:"version"`
+	Metadata    cycloneDXMetadata    `json:"metadata"`
+	Components  []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXMetadata struct {
+	Tools []cycloneDXTool `json:"tools"`
+}
+
+type cycloneDXTool struct {
+	Vendor string `json:"vendor"`
+	Name   string `json:"name"`
+}
+
+type cycloneDXComponent struct {
+	BOMRef     string              `json:"bom-ref"`
+	Type       string              `json:"type"`
+	Name       string              `json:"name"`
+	Version    string              `json:"version,omitempty"`
+	Scope      string              `json:"scope"`
+	PURL       string              `json:"purl,omitempty"`
+	CPE        string              `json:"cpe,omitempty"`
+	Licenses   []cycloneDXLicense  `json:"licenses,omitempty"`
+	Properties []cycloneDXProperty `json:"properties"`
+}
+
+type cycloneDXLicense struct {
+	License struct {
+		Name string `json:"name"`
+	} `json:"license"`
+}
+
+type cycloneDXProperty struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+func cycloneDX(components []sbomComponent) cycloneDXDocument {
+	doc := cycloneDXDocument{
+		BOMFormat:   "CycloneDX",
+		SpecVersion: "1.3",
+		Version:     1,
+		Metadata:    cycloneDXMetadata{Tools: []cycloneDXTool{{Vendor: "Cloud Native Buildpacks", Name: "lifecycle"}}},
+		Components:  []cycloneDXComponent{},
+	}
+	for i, c := range components {
+		component := cycloneDXComponent{
+			BOMRef:  fmt.Sprintf("%d-%s", i, c.Name),
+			Type:    "library",
+			Name:    c.Name,
+			Version: c.Version,
+			Scope:   "required",
+			PURL:    c.PURL,
+			CPE:     c.CPE,
+			Properties: []cycloneDXProperty{
+				{Name: "io.buildpacks.buildpack", Value: c.Buildpack.String()},
+				{Name: "io.buildpacks.scope", Value: c.Scope},
+			},
+		}
+		if c.Scope == sbomScopeBuild {
+			// build dependencies are not in the image
+			component.Scope = "excluded"
+		}
+		for _, l := range c.Licenses {
+			var license cycloneDXLicense
+			license.License.Name = l
+			component.Licenses = append(component.Licenses, license)
+		}
+		doc.Components = append(doc.Components, component)
+	}
+	return doc
+}
+
+type spdxDocument struct {
+	SPDXVersion       string           `json:"spdxVersion"`
+	DataLicense       string           `json:"dataLicense"`
+	SPDXID            string           `json:"SPDXID"`
+	Name              string           `json:"name"`
+	DocumentNamespace string           `json:"documentNamespace"`
+	CreationInfo      spdxCreationInfo `json:"creationInfo"`
+	DocumentDescribes []string         `json:"documentDescribes"`
+	Packages          []spdxPackage    `json:"packages"`
+}
+
+type spdxCreationInfo struct {
+	Created  string   `json:"created"`
+	Creators []string `json:"creators"`
+}
+
+type spdxPackage struct {
+	SPDXID           string            `json:"SPDXID"`
+	Name             string            `json:"name"`
+	VersionInfo      string            `json:"versionInfo,omitempty"`
+	DownloadLocation string            `json:"downloadLocation"`
+	FilesAnalyzed    bool              `json:"filesAnalyzed"`
+	LicenseConcluded string            `json:"licenseConcluded"`
+	LicenseDeclared  string            `json:"licenseDeclared"`
+	CopyrightText    string            `json:"copyrightText"`
+	Comment          string            `json:"comment"`
+	ExternalRefs     []spdxExternalRef `json:"externalRefs,omitempty"`
+}
+
+type spdxExternalRef struct {
+	ReferenceCategory string `json:"referenceCategory"`
+	ReferenceType     string `json:"referenceType"`
+	ReferenceLocator  string `json:"referenceLocator"`
+}
+
+func spdx(components []sbomComponent) (spdxDocument, error) {
+	doc := spdxDocument{
+		SPDXVersion: "SPDX-2.2",
+		DataLicense: "CC0-1.0",
+		SPDXID:      "SPDXRef-DOCUMENT",
+		Name:        "buildpacks-bom",
+		// the image timestamps are normalized, so the document uses the same creation time
+		CreationInfo:      spdxCreationInfo{Created: imgutil.NormalizedDateTime.Format("2006-01-02T15:04:05Z"), Creators: []string{"Tool: lifecycle"}},
+		DocumentDescribes: []string{},
+		Packages:          []spdxPackage{},
+	}
+	for i, c := range components {
+		pkg := spdxPackage{
+			SPDXID:           fmt.Sprintf("SPDXRef-Package-%d", i),
+			Name:             c.Name,
+			VersionInfo:      c.Version,
+			DownloadLocation: "NOASSERTION",
+			LicenseConcluded: "NOASSERTION",
+			LicenseDeclared:  "NOASSERTION",
+			CopyrightText:    "NOASSERTION",
+			Comment:          fmt.Sprintf("%s dependency provided by %s", c.Scope, c.Buildpack),
+		}
+		if len(c.Licenses) > 0 {
+			pkg.LicenseDeclared = strings.Join(c.Licenses, " AND ")
+		}
+		if c.PURL != "" {
+			pkg.ExternalRefs = append(pkg.ExternalRefs, spdxExternalRef{ReferenceCategory: "PACKAGE_MANAGER", ReferenceType: "purl", ReferenceLocator: c.PURL})
+		}
+		if c.CPE != "" {
+			pkg.ExternalRefs = append(pkg.ExternalRefs, spdxExternalRef{ReferenceCategory: "SECURITY", ReferenceType: "cpe23Type", ReferenceLocator: c.CPE})
+		}
+		doc.Packages = append(doc.Packages, pkg)
+		doc.DocumentDescribes = append(doc.DocumentDescribes, pkg.SPDXID)
+	}
+	// the namespace must be unique to the document, so it is derived from the packages it describes
+	data, err := json.Marshal(doc.Packages)
+	if err != nil {
+		return spdxDocument{}, err
+	}
+	doc.DocumentNamespace = fmt.Sprintf("https://buildpacks.io/spdx/%x", sha256.Sum256(data))
+	return doc, nil
+}
@@ -0,0 +1,161 @@
+package lifecycle_test
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/sclevine/spec"
+	"github.com/sclevine/spec/report"
+
+	"github.com/buildpacks/lifecycle"
+	h "github.com/buildpacks/lifecycle/testhelpers"
+)
+
+func TestSBOM(t *testing.T) {
+	spec.Run(t, "SBOM", testSBOM, spec.Report(report.Terminal{}))
+}
+
+func testSBOM(t *testing.T, when spec.G, it spec.S) {
+	var (
+		tmpDir    string
+		launchBOM []lifecycle.BOMEntry
+		buildBOM  []lifecycle.BOMEntry
+	)
+
+	it.Before(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "lifecycle.sbom")
+		h.AssertNil(t, err)
+
+		launchBOM = []lifecycle.BOMEntry{
+			{
+				Require: lifecycle.Require{
+					Name: "some-dep",
+					Metadata: map[string]interface{}{
+						"version":  "v1",
+						"purl":     "pkg:generic/some-dep@v1",
+						"cpe":      "cpe:2.3:a:some:some-dep:v1:*:*:*:*:*:*:*",
+						"licenses": []map[string]interface{}{{"type": "MIT"}, {"type": "Apache-2.0"}},
+					},
+				},
+				Buildpack: lifecycle.GroupBuildpack{ID: "A", Version: "v1", API: "0.5"},
+			},
+		}
+		buildBOM = []lifecycle.BOMEntry{
+			{
+				Require:   lifecycle.Require{Name: "build-dep", Version: "v2"},
+				Buildpack: lifecycle.GroupBuildpack{ID: "B", Version: "v2"},
+			},
+		}
+	})
+
+	it.After(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	readJSON := func(file string) map[string]interface{} {
+		t.Helper()
+		contents, err := ioutil.ReadFile(filepath.Join(tmpDir, "sbom", file))
+		h.AssertNil(t, err)
+		var doc map[string]interface{}
+		h.AssertNil(t, json.Unmarshal(contents, &doc))
+		return doc
+	}
+
+	when("#WriteSBOM", func() {
+		it("should write a cyclonedx document", func() {
+			h.AssertNil(t, lifecycle.WriteSBOM(filepath.Join(tmpDir, "sbom"), launchBOM, buildBOM))
+
+			doc := readJSON(lifecycle.SBOMCycloneDXFile)
+			h.AssertEq(t, doc["bomFormat"], "CycloneDX")
+			h.AssertEq(t, doc["specVersion"], "1.3")
+			if s := cmp.Diff(doc["components"], []interface{}{
+				map[string]interface{}{
+					"bom-ref": "0-some-dep",
+					"type":    "library",
+					"name":    "some-dep",
+					"version": "v1",
+					"scope":   "required",
+					"purl":    "pkg:generic/some-dep@v1",
+					"cpe":     "cpe:2.3:a:some:some-dep:v1:*:*:*:*:*:*:*",
+					"licenses": []interface{}{
+						map[string]interface{}{"license": map[string]interface{}{"name": "MIT"}},
+						map[string]interface{}{"license": map[string]interface{}{"name": "Apache-2.0"}},
+					},
+					"properties": []interface{}{
+						map[string]interface{}{"name": "io.buildpacks.buildpack", "value": "A@v1"},
+						map[string]interface{}{"name": "io.buildpacks.scope", "value": "launch"},
+					},
+				},
+				map[string]interface{}{
+					"bom-ref": "1-build-dep",
+					"type":    "library",
+					"name":    "build-dep",
+					"version": "v2",
+					"scope":   "excluded",
+					"properties": []interface{}{
+						map[string]interface{}{"name": "io.buildpacks.buildpack", "value": "B@v2"},
+						map[string]interface{}{"name": "io.buildpacks.scope", "value": "build"},
+					},
+				},
+			}); s != "" {
+				t.Fatalf("Unexpected components:\n%s\n", s)
+			}
+		})
+
+		it("should write an spdx document", func() {
+			h.AssertNil(t, lifecycle.WriteSBOM(filepath.Join(tmpDir, "sbom"), launchBOM, buildBOM))
+
+			doc := readJSON(lifecycle.SBOMSPDXFile)
+			h.AssertEq(t, doc["spdxVersion"], "SPDX-2.2")
+			h.AssertEq(t, doc["documentDescribes"], []interface{}{"SPDXRef-Package-0", "SPDXRef-Package-1"})
+			if s := cmp.Diff(doc["packages"], []interface{}{
+				map[string]interface{}{
+					"SPDXID":           "SPDXRef-Package-0",
+					"name":             "some-dep",
+					"versionInfo":      "v1",
+					"downloadLocation": "NOASSERTION",
+					"filesAnalyzed":    false,
+					"licenseConcluded": "NOASSERTION",
+					"licenseDeclared":  "MIT AND Apache-2.0",
+					"copyrightText":    "NOASSERTION",
+					"comment":          "launch dependency provided by A@v1",
+					"externalRefs": []interface{}{
+						map[string]interface{}{"referenceCategory": "PACKAGE_MANAGER", "referenceType": "purl", "referenceLocator": "pkg:generic/some-dep@v1"},
+						map[string]interface{}{"referenceCategory": "SECURITY", "referenceType": "cpe23Type", "referenceLocator": "cpe:2.3:a:some:some-dep:v1:*:*:*:*:*:*:*"},
+					},
+				},
+				map[string]interface{}{
+					"SPDXID":           "SPDXRef-Package-1",
+					"name":             "build-dep",
+					"versionInfo":      "v2",
+					"downloadLocation": "NOASSERTION",
+					"filesAnalyzed":    false,
+					"licenseConcluded": "NOASSERTION",
+					"licenseDeclared":  "NOASSERTION",
+					"copyrightText":    "NOASSERTION",
+					"comment":          "build dependency provided by B@v2",
+				},
+			}); s != "" {
+				t.Fatalf("Unexpected packages:\n%s\n", s)
+			}
+		})
+
+		it("should write the same documents for the same bom", func() {
+			h.AssertNil(t, lifecycle.WriteSBOM(filepath.Join(tmpDir, "sbom"), launchBOM, buildBOM))
+			first := readJSON(lifecycle.SBOMSPDXFile)
+			h.AssertNil(t, lifecycle.WriteSBOM(filepath.Join(tmpDir, "sbom"), launchBOM, buildBOM))
+			h.AssertEq(t, readJSON(lifecycle.SBOMSPDXFile), first)
+
+			buildBOM[0].Version = "v3"
+			h.AssertNil(t, lifecycle.WriteSBOM(filepath.Join(tmpDir, "sbom"), launchBOM, buildBOM))
+			if readJSON(lifecycle.SBOMSPDXFile)["documentNamespace"] == first["documentNamespace"] {
+				t.Fatal("Expected the document namespace to change with the bom")
+			}
+		})
+	})
+}
@@ -0,0 +1,279 @@
+package lifecycle
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	semverRegex     = regexp.MustCompile(`^v?(\d+|[xX*])(?:\.(\d+|[xX*]))?(?:\.(\d+|[xX*]))?(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+	comparatorRegex = regexp.MustCompile(`^(\^|~|>=|<=|>|<|=)?\s*(.*)$`)
+	hyphenRegex     = regexp.MustCompile(`\s+-\s+`)
+)
+
+// semver is a possibly partial semantic version; parts is the number of
+// leading numeric components that were specified (0 for "*").
+type semver struct {
+	major, minor, patch int
+	pre                 string
+	parts               int
+}
+
+func parseSemver(s string) (semver, bool) {
+	m := semverRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if m == nil {
+		return semver{}, false
+	}
+	var v semver
+	nums := []*int{&v.major, &v.minor, &v.patch}
+	for i, part := range m[1:4] {
+		if part == "" || part == "x" || part == "X" || part == "*" {
+			break
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return semver{}, false
+		}
+		*nums[i] = n
+		v.parts++
+	}
+	if v.parts == 3 {
+		v.pre = m[4]
+	}
+	return v, true
+}
+
+func (v semver) compare(o semver) int {
+	for _, d := range []int{v.major - o.major, v.minor - o.minor, v.patch - o.patch} {
+		if d < 0 {
+			return -1
+		}
+		if d > 0 {
+			return 1
+		}
+	}
+	switch {
+	case v.pre == o.pre:
+		return 0
+	case v.pre == "":
+		return 1
+	case o.pre == "":
+		return -1
+	default:
+		return comparePrerelease(v.pre, o.pre)
+	}
+}
+
+// comparePrerelease compares dot-separated prerelease identifiers by precedence: numeric identifiers
+// numerically and below alphanumeric ones, which compare in ASCII order, and a shorter list of equal
+// identifiers first.
+func comparePrerelease(a, b string) int {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+		case aErr == nil:
+			return -1
+		case bErr == nil:
+			return 1
+		case as[i] != bs[i]:
+			if as[i] < bs[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	default:
+		return 0
+	}
+}
+
+// next returns the lowest version above every version matched by the partial version v.
+func (v semver) next() semver {
+	switch v.parts {
+	case 1:
+		return semver{major: v.major + 1, parts: 3}
+	case 2:
+		return semver{major: v.major, minor: v.minor + 1, parts: 3}
+	default:
+		return semver{major: v.major, minor: v.minor, patch: v.patch + 1, parts: 3}
+	}
+}
+
+func (v semver) floor() semver {
+	v.parts = 3
+	return v
+}
+
+type bound struct {
+	version   semver
+	inclusive bool
+	unbounded bool
+}
+
+// versionRange is the interval of versions between min and max.
+type versionRange struct {
+	min, max bound
+}
+
+func (r versionRange) empty() bool {
+	if r.min.unbounded || r.max.unbounded {
+		return false
+	}
+	c := r.min.version.compare(r.max.version)
+	return c > 0 || (c == 0 && !(r.min.inclusive && r.max.inclusive))
+}
+
+func (r versionRange) intersect(o versionRange) versionRange {
+	out := r
+	if !o.min.unbounded {
+		if out.min.unbounded {
+			out.min = o.min
+		} else if c := o.min.version.compare(out.min.version); c > 0 || (c == 0 && !o.min.inclusive) {
+			out.min = o.min
+		}
+	}
+	if !o.max.unbounded {
+		if out.max.unbounded {
+			out.max = o.max
+		} else if c := o.max.version.compare(out.max.version); c < 0 || (c == 0 && !o.max.inclusive) {
+			out.max = o.max
+		}
+	}
+	return out
+}
+
+var anyVersion = versionRange{min: bound{unbounded: true}, max: bound{unbounded: true}}
+
+// versionConstraint is a union of version ranges, parsed from npm-style
+// constraints such as "^14.2", ">=1.2 <2 || 3.x" or "1.2 - 1.4".
+type versionConstraint []versionRange
+
+func parseVersionConstraint(s string) (versionConstraint, bool) {
+	var out versionConstraint
+	for _, alt := range strings.Split(s, "||") {
+		r := anyVersion
+		alt = strings.TrimSpace(alt)
+		if alt == "" {
+			return nil, false
+		}
+		if parts := hyphenRegex.Split(alt, 2); len(parts) == 2 {
+			lo, ok := parseSemver(parts[0])
+			if !ok {
+				return nil, false
+			}
+			hi, ok := parseSemver(parts[1])
+			if !ok {
+				return nil, false
+			}
+			r = r.intersect(comparatorRange(">=", lo)).intersect(comparatorRange("<=", hi))
+			out = append(out, r)
+			continue
+		}
+		for _, comparator := range strings.Fields(normalizeOperators(alt)) {
+			m := comparatorRegex.FindStringSubmatch(comparator)
+			v, ok := parseSemver(m[2])
+			if !ok {
+				return nil, false
+			}
+			r = r.intersect(comparatorRange(m[1], v))
+		}
+		out = append(out, r)
+	}
+	return out, true
+}
+
+// normalizeOperators removes whitespace between an operator and its version, e.g. ">= 1.2" becomes ">=1.2".
+func normalizeOperators(s string) string {
+	for _, op := range []string{">=", "<=", ">", "<", "=", "^", "~"} {
+		s = strings.ReplaceAll(s, op+" ", op)
+	}
+	return s
+}
+
+func comparatorRange(op string, v semver) versionRange {
+	if v.parts == 0 {
+		if op == ">" || op == "<" {
+			return versionRange{min: bound{inclusive: true}, max: bound{}} // matches nothing
+		}
+		return anyVersion
+	}
+	upTo := func(max semver) versionRange {
+		return versionRange{min: bound{version: v.floor(), inclusive: true}, max: bound{version: max}}
+	}
+	switch op {
+	case "^":
+		switch {
+		case v.major != 0 || v.parts == 1:
+			return upTo(semver{major: v.major + 1, parts: 3})
+		case v.minor != 0 || v.parts == 2:
+			return upTo(semver{minor: v.minor + 1, parts: 3})
+		default:
+			return upTo(semver{patch: v.patch + 1, parts: 3})
+		}
+	case "~":
+		if v.parts == 1 {
+			return upTo(semver{major: v.major + 1, parts: 3})
+		}
+		return upTo(semver{major: v.major, minor: v.minor + 1, parts: 3})
+	case ">":
+		if v.parts < 3 {
+			return versionRange{min: bound{version: v.next(), inclusive: true}, max: bound{unbounded: true}}
+		}
+		return versionRange{min: bound{version: v}, max: bound{unbounded: true}}
+	case ">=":
+		return versionRange{min: bound{version: v.floor(), inclusive: true}, max: bound{unbounded: true}}
+	case "<":
+		return versionRange{min: bound{unbounded: true}, max: bound{version: v.floor()}}
+	case "<=":
+		if v.parts < 3 {
+			return versionRange{min: bound{unbounded: true}, max: bound{version: v.next()}}
+		}
+		return versionRange{min: bound{unbounded: true}, max: bound{version: v, inclusive: true}}
+	default:
+		if v.parts < 3 {
+			return upTo(v.next())
+		}
+		return versionRange{min: bound{version: v, inclusive: true}, max: bound{version: v, inclusive: true}}
+	}
+}
+
+// overlaps reports whether any version satisfies both constraints.
+func (c versionConstraint) overlaps(o versionConstraint) bool {
+	for _, r := range c {
+		for _, or := range o {
+			if !r.intersect(or).empty() {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// versionsOverlap reports whether a provided version or range can satisfy a required
+// version or range. Values that are not semantic versions must match exactly.
+func versionsOverlap(provided, required string) bool {
+	if provided == "" || required == "" {
+		return true
+	}
+	pc, pok := parseVersionConstraint(provided)
+	rc, rok := parseVersionConstraint(required)
+	if !pok || !rok {
+		return provided == required
+	}
+	return pc.overlaps(rc)
+}
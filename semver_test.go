@@ -0,0 +1,144 @@
+package lifecycle
+
+import (
+	"testing"
+)
+
+func TestSemverCompare(t *testing.T) {
+	// each version is lower than the next, as in the example of SemVer §11
+	ordered := []string{
+		"1.0.0-alpha",
+		"1.0.0-alpha.1",
+		"1.0.0-alpha.beta",
+		"1.0.0-beta",
+		"1.0.0-beta.2",
+		"1.0.0-beta.11",
+		"1.0.0-rc.1",
+		"1.0.0-rc.9",
+		"1.0.0-rc.10",
+		"1.0.0",
+		"1.0.1",
+		"1.1.0",
+		"2.0.0",
+	}
+	for i := range ordered {
+		for j := range ordered {
+			a, _ := parseSemver(ordered[i])
+			b, _ := parseSemver(ordered[j])
+			want := 0
+			if i < j {
+				want = -1
+			} else if i > j {
+				want = 1
+			}
+			if got := a.compare(b); got != want {
+				t.Errorf("compare(%s, %s) = %d, expected %d", ordered[i], ordered[j], got, want)
+			}
+		}
+	}
+}
+
+func TestParseSemver(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want semver
+		ok   bool
+	}{
+		{"1.2.3", semver{major: 1, minor: 2, patch: 3, parts: 3}, true},
+		{"v1.2.3", semver{major: 1, minor: 2, patch: 3, parts: 3}, true},
+		{"1.2.3-rc.1+build.5", semver{major: 1, minor: 2, patch: 3, pre: "rc.1", parts: 3}, true},
+		{"1.2", semver{major: 1, minor: 2, parts: 2}, true},
+		{"1.x", semver{major: 1, parts: 1}, true},
+		{"*", semver{}, true},
+		{"latest", semver{}, false},
+		{"1.2.3.4", semver{}, false},
+	} {
+		got, ok := parseSemver(tc.in)
+		if ok != tc.ok || got != tc.want {
+			t.Errorf("parseSemver(%q) = %+v, %t, expected %+v, %t", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestVersionsOverlap(t *testing.T) {
+	for _, tc := range []struct {
+		provided, required string
+		want               bool
+	}{
+		// exact versions
+		{"1.2.3", "1.2.3", true},
+		{"1.2.3", "1.2.4", false},
+		{"1.2.3", "=1.2.3", true},
+		{"", "^1.0", true},
+		{"1.2.3", "", true},
+
+		// comparators
+		{"1.2.3", ">=1.2.3", true},
+		{"1.2.3", ">1.2.3", false},
+		{"1.2.3", "<1.2.3", false},
+		{"1.2.3", "<=1.2.3", true},
+		{"1.2.3", ">= 1.2 < 2", true},
+		{"1.9.9", ">1.2", true},
+		{"1.2.9", ">1.2", false},
+		{"1.2.9", "<=1.2", true},
+		{"1.3.0", "<=1.2", false},
+
+		// partial versions and wildcards
+		{"1.4.0", "1.x", true},
+		{"2.0.0", "1.x", false},
+		{"1.2.7", "1.2", true},
+		{"1.3.0", "1.2", false},
+		{"0.0.1", "*", true},
+
+		// caret
+		{"14.2.0", "^14.2", true},
+		{"14.9.1", "^14.2", true},
+		{"14.1.9", "^14.2", false},
+		{"15.0.0", "^14.2", false},
+		{"0.2.5", "^0.2.3", true},
+		{"0.3.0", "^0.2.3", false},
+		{"0.0.3", "^0.0.3", true},
+		{"0.0.4", "^0.0.3", false},
+
+		// tilde
+		{"6.14.10", "~6.14.9", true},
+		{"6.15.0", "~6.14.9", false},
+		{"6.14.8", "~6.14.9", false},
+		{"6.9.0", "~6", true},
+		{"7.0.0", "~6", false},
+
+		// hyphen ranges
+		{"1.3.0", "1.2 - 1.4", true},
+		{"1.4.0", "1.2.0 - 1.4.0", true},
+		{"1.4.1", "1.2.0 - 1.4.0", false},
+
+		// unions
+		{"6.14.4", "~6.14.9 || 6.14.4", true},
+		{"6.14.5", "~6.14.9 || 6.14.4", false},
+		{"3.1.0", ">=1.2 <2 || 3.x", true},
+		{"2.5.0", ">=1.2 <2 || 3.x", false},
+		{"5.0.0", "^1.0 ||", false},
+		{"5.0.0", "|| ^1.0", false},
+		{"5.0.0", "^1.0 || || ^2.0", false},
+
+		// ranges on both sides
+		{"^16", ">=12 <14", false},
+		{">=12 <14", "^13.1", true},
+		{"1.x || 3.x", "^2.0 || ^3.5", true},
+
+		// prereleases
+		{"1.0.0-rc.10", ">1.0.0-rc.9", true},
+		{"1.0.0-rc.9", ">1.0.0-rc.10", false},
+		{"1.0.0-rc.1", "<1.0.0", true},
+		{"1.0.0-beta.11", ">=1.0.0-beta.2 <1.0.0-rc", true},
+		{"1.0.0-alpha", "1.0.0", false},
+
+		// values that aren't semantic versions match exactly
+		{"latest", "latest", true},
+		{"latest", "^1.0", false},
+	} {
+		if got := versionsOverlap(tc.provided, tc.required); got != tc.want {
+			t.Errorf("versionsOverlap(%q, %q) = %t, expected %t", tc.provided, tc.required, got, tc.want)
+		}
+	}
+}
@@ -12,6 +12,17 @@ for /f "tokens=* USEBACKQ" %%F in (`type %bp_dir%\buildpack.toml ^| yj -t ^| jq
   set bp_version=%%F
 )
 
+if exist detect-interleave-%bp_id%-%bp_version% (
+  echo detect err before out>&2
+  timeout /t 1 /nobreak > nul
+  echo detect out after err
+)
+
+if exist detect-secret-%bp_id%-%bp_version% (
+  echo detect secret: %SOME_SECRET%
+  echo detect secret: %SOME_SECRET%>&2
+)
+
 echo detect out: %bp_id%@%bp_version%
 call :echon detect err: %bp_id%@%bp_version%>&2
 
@@ -23,6 +34,12 @@ if exist detect-plan-%bp_id%-%bp_version%.toml (
   type detect-plan-%bp_id%-%bp_version%.toml > %plan_path%
 )
 
+if exist detect-sleep-%bp_id%-%bp_version% (
+  for /f "tokens=* USEBACKQ" %%F in (`type detect-sleep-%bp_id%-%bp_version%`) do (
+    timeout /t %%F /nobreak > nul
+  )
+)
+
 if exist detect-status-%bp_id%-%bp_version% (
   for /f "tokens=* USEBACKQ" %%F in (`type detect-status-%bp_id%-%bp_version%`) do (
     exit /b %%F
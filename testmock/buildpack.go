@@ -49,3 +49,17 @@ func (mr *MockBuildpackMockRecorder) Build(arg0, arg1 interface{}) *gomock.Call
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Build", reflect.TypeOf((*MockBuildpack)(nil).Build), arg0, arg1)
 }
+
+// ConfigFile mocks base method
+func (m *MockBuildpack) ConfigFile() *lifecycle.BuildpackTOML {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ConfigFile")
+	ret0, _ := ret[0].(*lifecycle.BuildpackTOML)
+	return ret0
+}
+
+// ConfigFile indicates an expected call of ConfigFile
+func (mr *MockBuildpackMockRecorder) ConfigFile() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ConfigFile", reflect.TypeOf((*MockBuildpack)(nil).ConfigFile))
+}
@@ -29,6 +29,12 @@ func ReadGroup(path string) (BuildpackGroup, error) {
 	return group, err
 }
 
+func ReadPlan(path string) (BuildPlan, error) {
+	var plan BuildPlan
+	_, err := toml.DecodeFile(path, &plan)
+	return plan, err
+}
+
 func ReadOrder(path string) (BuildpackOrder, error) {
 	var order struct {
 		Order BuildpackOrder `toml:"order"`